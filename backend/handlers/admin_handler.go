@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"mini-toolbox/models"
+	"mini-toolbox/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler 管理接口处理器
+type AdminHandler struct {
+	janitor *models.Janitor
+	token   string // 管理令牌，为空时禁用管理接口
+}
+
+// NewAdminHandler 创建新的管理接口处理器
+func NewAdminHandler(janitor *models.Janitor, token string) *AdminHandler {
+	return &AdminHandler{
+		janitor: janitor,
+		token:   token,
+	}
+}
+
+// RequireToken 校验 X-Admin-Token 请求头
+func (h *AdminHandler) RequireToken(c *gin.Context) {
+	if h.token == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, utils.ResponseError{
+			Error: "管理接口未启用",
+		})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Admin-Token")), []byte(h.token)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, utils.ResponseError{
+			Error: "管理令牌无效",
+		})
+		return
+	}
+	c.Next()
+}
+
+// Sweep 立即执行一次文件清理
+func (h *AdminHandler) Sweep(c *gin.Context) {
+	report, err := h.janitor.Sweep(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ResponseError{
+			Error: "清理文件失败: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, utils.ResponseSuccess{
+		Message: "清理完成",
+		Data:    report,
+	})
+}
+
+// GetRetention 查看文件保留策略
+func (h *AdminHandler) GetRetention(c *gin.Context) {
+	policy := h.janitor.Policy()
+	c.JSON(http.StatusOK, gin.H{
+		"uploadTTL":     policy.UploadTTL.String(),
+		"compressedTTL": policy.CompressedTTL.String(),
+		"maxBytes":      policy.MaxBytes,
+		"interval":      policy.Interval.String(),
+	})
+}
@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"mini-toolbox/models"
+	"mini-toolbox/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxBatchFiles       = 100               // 单次批量处理的最大文件数
+	maxBatchRequestSize = 200 * 1024 * 1024 // 批量请求体的最大字节数
+)
+
+// BatchItemResult 批量处理中单个文件的结果
+type BatchItemResult struct {
+	Filename       string                 `json:"filename"`                 // 原始文件名
+	Success        bool                   `json:"success"`                  // 是否处理成功
+	Error          string                 `json:"error,omitempty"`          // 失败原因
+	Code           string                 `json:"code,omitempty"`           // 内容校验失败时的错误码
+	CompressedFile string                 `json:"compressedFile,omitempty"` // 压缩后文件名
+	Result         *models.CompressResult `json:"result,omitempty"`         // 压缩结果
+}
+
+// batchSource 批量处理的输入文件
+type batchSource struct {
+	name        string
+	contentType string
+	open        func() (io.ReadCloser, error)
+	size        int64
+}
+
+// BatchCompress 批量上传并压缩图片
+// 支持多个 image 字段，或一个 archive 字段上传的 ZIP 压缩包，所有文件共享压缩选项
+func (h *ImageHandler) BatchCompress(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBatchRequestSize)
+	if err := c.Request.ParseMultipartForm(h.maxFileSize); err != nil {
+		c.JSON(http.StatusBadRequest, utils.LegacyErrorResponse{
+			Success: false,
+			Message: "文件太大或请求格式错误",
+		})
+		return
+	}
+	defer c.Request.MultipartForm.RemoveAll()
+
+	options, err := h.parseCompressionOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.LegacyErrorResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// 获取所有者与过期时间
+	meta, err := h.parseRequestMeta(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.LegacyErrorResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	sources, cleanup, err := h.collectBatchSources(c.Request.MultipartForm)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.LegacyErrorResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	defer cleanup()
+
+	// 逐个处理，单个文件失败不影响其他文件
+	batchID := utils.NewID()
+	results := make([]BatchItemResult, 0, len(sources))
+	succeeded := 0
+	for _, source := range sources {
+		item := h.compressBatchItem(c.Request.Context(), meta, source, options)
+		if item.Success {
+			succeeded++
+		}
+		results = append(results, item)
+	}
+
+	data := gin.H{
+		"results":   results,
+		"total":     len(results),
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+	}
+
+	if succeeded == 0 {
+		c.JSON(http.StatusUnprocessableEntity, utils.LegacySuccessResponse{
+			Success: false,
+			Message: "所有图片处理失败",
+			Data:    data,
+		})
+		return
+	}
+
+	// 打包所有压缩结果
+	zipFilename := fmt.Sprintf("batch_%s.zip", batchID)
+	if err := h.writeBatchZip(c.Request.Context(), zipFilename, results); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.LegacyErrorResponse{
+			Success: false,
+			Message: fmt.Sprintf("打包压缩结果失败: %v", err),
+		})
+		return
+	}
+	data["zipFile"] = zipFilename
+	data["zipUrl"] = fmt.Sprintf("/api/v1/images/download/%s", zipFilename)
+
+	c.JSON(http.StatusOK, utils.LegacySuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("批量处理完成，成功 %d 个，失败 %d 个", succeeded, len(results)-succeeded),
+		Data:    data,
+	})
+}
+
+// collectBatchSources 收集批量处理的输入文件
+func (h *ImageHandler) collectBatchSources(form *multipart.Form) ([]batchSource, func(), error) {
+	noop := func() {}
+	archives := form.File["archive"]
+	images := form.File["image"]
+
+	switch {
+	case len(archives) > 0 && len(images) > 0:
+		return nil, noop, fmt.Errorf("不能同时上传图片和压缩包")
+	case len(archives) > 1:
+		return nil, noop, fmt.Errorf("一次只能上传一个压缩包")
+	case len(archives) == 1:
+		return h.zipSources(archives[0])
+	case len(images) == 0:
+		return nil, noop, fmt.Errorf("请选择要上传的图片文件")
+	case len(images) > maxBatchFiles:
+		return nil, noop, fmt.Errorf("单次最多处理 %d 个文件", maxBatchFiles)
+	}
+
+	sources := make([]batchSource, 0, len(images))
+	for _, fileHeader := range images {
+		fileHeader := fileHeader
+		sources = append(sources, batchSource{
+			name:        utils.SanitizeFilename(fileHeader.Filename),
+			contentType: fileHeader.Header.Get("Content-Type"),
+			size:        fileHeader.Size,
+			open:        func() (io.ReadCloser, error) { return fileHeader.Open() },
+		})
+	}
+	return sources, noop, nil
+}
+
+// zipSources 从上传的 ZIP 压缩包中收集图片文件
+func (h *ImageHandler) zipSources(fileHeader *multipart.FileHeader) ([]batchSource, func(), error) {
+	noop := func() {}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, noop, fmt.Errorf("无法读取压缩包")
+	}
+	reader, err := zip.NewReader(file, fileHeader.Size)
+	if err != nil {
+		file.Close()
+		return nil, noop, fmt.Errorf("无效的 ZIP 压缩包")
+	}
+
+	sources := make([]batchSource, 0, len(reader.File))
+	for _, entry := range reader.File {
+		name := path.Base(entry.Name)
+		// 跳过目录、隐藏文件以及 macOS 生成的资源文件
+		if entry.FileInfo().IsDir() || strings.HasPrefix(name, ".") || strings.HasPrefix(entry.Name, "__MACOSX/") {
+			continue
+		}
+		if len(sources) >= maxBatchFiles {
+			file.Close()
+			return nil, noop, fmt.Errorf("单次最多处理 %d 个文件", maxBatchFiles)
+		}
+		entry := entry
+		sources = append(sources, batchSource{
+			name: utils.SanitizeFilename(name),
+			size: int64(entry.UncompressedSize64),
+			open: func() (io.ReadCloser, error) { return entry.Open() },
+		})
+	}
+	if len(sources) == 0 {
+		file.Close()
+		return nil, noop, fmt.Errorf("压缩包中没有文件")
+	}
+	return sources, func() { file.Close() }, nil
+}
+
+// compressBatchItem 保存并压缩批量处理中的单个文件
+func (h *ImageHandler) compressBatchItem(ctx context.Context, meta requestMeta, source batchSource, options models.CompressionOption) BatchItemResult {
+	item := BatchItemResult{Filename: source.name}
+
+	upload, err := h.saveBatchItem(ctx, meta, source)
+	if err != nil {
+		item.Error = err.Error()
+		item.Code = errorCode(err)
+		return item
+	}
+
+	// 压缩图片，相同文件与选项直接返回已有结果
+	result, err := h.imageService.CompressCached(ctx, upload.Key, options)
+	if err != nil {
+		h.discardUpload(upload)
+		item.Error = fmt.Sprintf("图片压缩失败: %v", err)
+		item.Code = errorCode(err)
+		return item
+	}
+
+	item.Success = true
+	item.CompressedFile = result.Filename
+	h.recordResult(meta, upload.Path, upload.Name, result, options)
+	item.Result = result
+	return item
+}
+
+// saveBatchItem 校验并保存单个输入文件
+func (h *ImageHandler) saveBatchItem(ctx context.Context, meta requestMeta, source batchSource) (*models.StoredUpload, error) {
+	if !h.imageService.ValidateImageFormat(source.name) {
+		return nil, fmt.Errorf("不支持的文件格式，支持的格式: %v", h.imageService.GetSupportedFormats())
+	}
+	if source.size > h.maxFileSize {
+		return nil, fmt.Errorf("文件大小超过限制 %d MB", h.maxFileSize/(1024*1024))
+	}
+
+	src, err := source.open()
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败")
+	}
+	defer src.Close()
+
+	// 压缩包中声明的大小不可信，读取时再次限制
+	data, err := h.readUpload(source.name, source.contentType, src)
+	if err != nil {
+		return nil, err
+	}
+	upload, err := h.saveUpload(ctx, meta, source.name, source.contentType, data)
+	if err != nil {
+		return nil, fmt.Errorf("保存文件失败")
+	}
+	return upload, nil
+}
+
+// writeBatchZip 将批量处理成功的压缩结果打包后保存到压缩结果存储
+// 先写入临时文件以确定大小，再整体写入存储
+func (h *ImageHandler) writeBatchZip(ctx context.Context, zipFilename string, results []BatchItemResult) error {
+	tmp, err := os.CreateTemp("", "batch-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// 条目使用清理后的显示文件名，重名时依次添加 " (n)" 后缀
+	writer := zip.NewWriter(tmp)
+	used := make(map[string]bool)
+	for _, item := range results {
+		if !item.Success {
+			continue
+		}
+		name := item.CompressedFile
+		if item.Result != nil && item.Result.DisplayName != "" {
+			name = item.Result.DisplayName
+		}
+		entryName := uniqueEntryName(utils.SanitizeFilename(name), used)
+		if err := h.addFileToZip(ctx, writer, item.CompressedFile, entryName); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return h.compressed.Put(ctx, zipFilename, tmp, size, "application/zip")
+}
+
+// uniqueEntryName 返回未使用过的条目名，重名（不区分大小写）时在扩展名前添加 " (n)"
+func uniqueEntryName(name string, used map[string]bool) string {
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	candidate := name
+	for n := 1; used[strings.ToLower(candidate)]; n++ {
+		candidate = fmt.Sprintf("%s (%d)%s", stem, n, ext)
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// addFileToZip 以不压缩方式将压缩结果 key 以 entryName 写入 ZIP，图片已经压缩过
+func (h *ImageHandler) addFileToZip(ctx context.Context, writer *zip.Writer, key, entryName string) error {
+	file, err := h.compressed.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entry, err := writer.CreateHeader(&zip.FileHeader{
+		Name:     entryName,
+		Method:   zip.Store,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, file)
+	return err
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"mini-toolbox/models"
+	"mini-toolbox/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxOwnerLength = 64                  // 所有者标识的最大长度
+	maxExpiresIn   = 30 * 24 * time.Hour // expiresIn 允许的最长保留时间
+)
+
+// requestMeta 与压缩效果无关的请求信息
+type requestMeta struct {
+	owner     string     // 所有者，来自 X-Owner 请求头
+	expiresAt *time.Time // 由 expiresIn 计算的过期时间，为空时按默认保留时间清理
+	palette   int        // 压缩响应中附带的主色数量，0 表示不提取
+}
+
+// requestOwner 返回 X-Owner 请求头中的所有者，未提供时为空，空所有者同样只能访问自己的记录
+func requestOwner(c *gin.Context) string {
+	owner := strings.TrimSpace(c.GetHeader("X-Owner"))
+	if len(owner) > maxOwnerLength {
+		owner = owner[:maxOwnerLength]
+	}
+	return owner
+}
+
+// parseRequestMeta 解析所有者、expiresIn 与 palette
+// expiresIn 支持时长（如 30m、2h）或秒数
+func (h *ImageHandler) parseRequestMeta(c *gin.Context) (requestMeta, error) {
+	meta := requestMeta{owner: requestOwner(c)}
+
+	if value := strings.TrimSpace(c.PostForm("expiresIn")); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			seconds, convErr := strconv.Atoi(value)
+			if convErr != nil {
+				return meta, fmt.Errorf("无效的过期时间: %s", value)
+			}
+			duration = time.Duration(seconds) * time.Second
+		}
+		if duration <= 0 || duration > maxExpiresIn {
+			return meta, fmt.Errorf("过期时间必须在 1 秒到 %d 天之间", int(maxExpiresIn.Hours()/24))
+		}
+		expiresAt := time.Now().Add(duration)
+		meta.expiresAt = &expiresAt
+	}
+
+	if value := strings.TrimSpace(c.PostForm("palette")); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return meta, fmt.Errorf("无效的主色数量: %s", value)
+		}
+		if size != 0 {
+			if err := models.ValidatePaletteSize(size); err != nil {
+				return meta, err
+			}
+		}
+		meta.palette = size
+	}
+	return meta, nil
+}
+
+// saveUpload 保存上传文件，并在目录中登记上传 ID、显示文件名、所有者、过期时间与原始图片的感知哈希
+// 相同内容共用同一个文件，目录按引用该文件的上传记录计数；哈希计算失败时不影响保存
+func (h *ImageHandler) saveUpload(ctx context.Context, meta requestMeta, filename, contentType string, data []byte) (*models.StoredUpload, error) {
+	upload, err := h.imageService.SaveUpload(ctx, filename, contentType, data)
+	if err != nil {
+		return nil, err
+	}
+	hashes := h.catalog.UploadHashes(upload.Key)
+	if hashes == nil {
+		hashes, _ = h.imageService.HashImage(data)
+	}
+	err = h.catalog.AddUpload(models.UploadRecord{
+		ID:        upload.ID,
+		Key:       upload.Key,
+		Name:      upload.Name,
+		Owner:     meta.owner,
+		ExpiresAt: meta.expiresAt,
+		Hashes:    hashes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	h.janitor.Touch(models.AreaUploads, upload.Key)
+	return upload, nil
+}
+
+// uploadRecord 根据上传文件名 <id><扩展名> 或上传 ID 查找上传记录
+func (h *ImageHandler) uploadRecord(filename string) (models.UploadRecord, error) {
+	record, err := h.catalog.Upload(strings.TrimSuffix(filename, filepath.Ext(filename)))
+	if err != nil {
+		return record, storage.ErrNotFound
+	}
+	return record, nil
+}
+
+// ownedUpload 查找请求所有者的上传记录，其他所有者的上传视为不存在
+func (h *ImageHandler) ownedUpload(owner, filename string) (models.UploadRecord, error) {
+	record, err := h.uploadRecord(filename)
+	if err == nil && record.Owner != owner {
+		return models.UploadRecord{}, storage.ErrNotFound
+	}
+	return record, err
+}
+
+// recordResult 将压缩结果写入请求所有者的目录记录，结果的显示文件名由本次请求的原始文件名生成
+// 目录写入失败不影响本次压缩结果，只记录日志
+func (h *ImageHandler) recordResult(meta requestMeta, uploadID, originalName string, result *models.CompressResult, options models.CompressionOption) {
+	result.DisplayName = models.CompressedDisplayName(originalName, result.Filename)
+	_, _, err := h.catalog.Add(models.ImageRecord{
+		Filename:       result.Filename,
+		DisplayName:    result.DisplayName,
+		UploadID:       uploadID,
+		OriginalName:   originalName,
+		SourceSHA256:   result.SourceSHA256,
+		SHA256:         result.SHA256,
+		Width:          result.Width,
+		Height:         result.Height,
+		Options:        options,
+		OriginalSize:   result.OriginalSize,
+		CompressedSize: result.CompressedSize,
+		Owner:          meta.owner,
+		ExpiresAt:      meta.expiresAt,
+		Hashes:         result.Hashes,
+	})
+	if err != nil {
+		log.Printf("写入图片目录失败: %v", err)
+	}
+	if result.Cached {
+		h.janitor.Touch(models.AreaCompressed, result.Filename)
+	}
+}
+
+// parseCatalogQuery 解析列表接口的筛选、排序与分页参数，所有者取自 X-Owner 请求头
+func parseCatalogQuery(c *gin.Context) (models.CatalogQuery, error) {
+	query := models.CatalogQuery{
+		Owner:    requestOwner(c),
+		Format:   c.Query("format"),
+		Name:     c.Query("q"),
+		UploadID: c.Query("uploadId"),
+		Sort:     c.Query("sort"),
+		Desc:     c.DefaultQuery("order", "desc") == "desc",
+	}
+	if page := c.Query("page"); page != "" {
+		value, err := strconv.Atoi(page)
+		if err != nil {
+			return query, models.ErrInvalidInput
+		}
+		query.Page = value
+	}
+	if pageSize := c.Query("pageSize"); pageSize != "" {
+		value, err := strconv.Atoi(pageSize)
+		if err != nil {
+			return query, models.ErrInvalidInput
+		}
+		query.PageSize = value
+	}
+	return query, query.Normalize()
+}
+
+// isBatchArchive 判断是否为批量处理生成的 ZIP，这类文件不记录在图片目录中
+func isBatchArchive(filename string) bool {
+	return strings.HasPrefix(filename, "batch_") && strings.EqualFold(filepath.Ext(filename), ".zip")
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mini-toolbox/models"
+	"mini-toolbox/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DiffImages 生成两张图片的差异热力图、左右拼接图与差异统计
+// 图片分别通过 image1/image2 上传或 filename1/filename2 指定，threshold 为通道误差阈值 (0-255)
+// output 为 json（默认，图片以 data URI 返回）、heatmap 或 composite（直接返回 PNG，统计放在响应头中）
+func (h *ImageHandler) DiffImages(c *gin.Context) {
+	if err := c.Request.ParseMultipartForm(2 * h.maxFileSize); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{
+			Error: "文件太大或请求格式错误",
+		})
+		return
+	}
+
+	output := c.DefaultPostForm("output", "json")
+	if output != "json" && output != "heatmap" && output != "composite" {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{
+			Error: "output 只能是 json、heatmap 或 composite",
+		})
+		return
+	}
+	threshold, err := postFormInt(c, "threshold", models.DefaultDiffThreshold)
+	if err == nil {
+		err = models.ValidateDiffThreshold(threshold)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	first, status, err := h.loadComparisonImage(c, "image1", "filename1")
+	if err != nil {
+		c.JSON(status, utils.ResponseError{
+			Error: "第一张图片: " + err.Error(),
+		})
+		return
+	}
+	second, status, err := h.loadComparisonImage(c, "image2", "filename2")
+	if err != nil {
+		c.JSON(status, utils.ResponseError{
+			Error: "第二张图片: " + err.Error(),
+		})
+		return
+	}
+
+	diff, err := h.imageService.DiffImages(first.data, second.data, threshold)
+	if err != nil {
+		status := compressErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, utils.ResponseError{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	switch output {
+	case "heatmap", "composite":
+		data := diff.Heatmap
+		if output == "composite" {
+			data = diff.Composite
+		}
+		stats := diff.Stats
+		c.Header("X-Diff-Pixels", strconv.Itoa(stats.DiffPixels))
+		c.Header("X-Diff-Percent", strconv.FormatFloat(stats.DiffPercent, 'f', -1, 64))
+		c.Header("X-Diff-PSNR", strconv.FormatFloat(stats.Metrics.PSNR, 'f', -1, 64))
+		c.Header("X-Diff-SSIM", strconv.FormatFloat(stats.Metrics.SSIM, 'f', -1, 64))
+		c.Data(http.StatusOK, "image/png", data)
+	default:
+		c.JSON(http.StatusOK, utils.ResponseSuccess{
+			Message: "差异比较完成",
+			Data: gin.H{
+				"images":    []*comparisonImage{first, second},
+				"stats":     diff.Stats,
+				"heatmap":   pngDataURI(diff.Heatmap),
+				"composite": pngDataURI(diff.Composite),
+			},
+		})
+	}
+}
+
+// pngDataURI 将 PNG 内容编码为 data URI
+func pngDataURI(data []byte) string {
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(data)
+}
@@ -1,14 +1,18 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"mini-toolbox/models"
+	"mini-toolbox/storage"
 	"mini-toolbox/utils"
 
 	"github.com/gin-gonic/gin"
@@ -16,19 +20,25 @@ import (
 
 // ImageHandler 图片处理器
 type ImageHandler struct {
-	imageService  models.ImageService
-	uploadDir     string
-	compressedDir string
-	maxFileSize   int64 // 最大文件大小（字节）
+	imageService models.ImageService
+	jobs         *models.JobQueue
+	catalog      models.ImageCatalog // 压缩结果目录
+	janitor      *models.Janitor     // 过期文件清理任务
+	uploads      storage.Storage     // 原始上传文件存储
+	compressed   storage.Storage     // 压缩结果存储
+	maxFileSize  int64               // 最大文件大小（字节）
 }
 
 // NewImageHandler 创建新的图片处理器
-func NewImageHandler(imageService models.ImageService, uploadDir, compressedDir string) *ImageHandler {
+func NewImageHandler(imageService models.ImageService, jobs *models.JobQueue, catalog models.ImageCatalog, janitor *models.Janitor, uploads, compressed storage.Storage) *ImageHandler {
 	return &ImageHandler{
-		imageService:  imageService,
-		uploadDir:     uploadDir,
-		compressedDir: compressedDir,
-		maxFileSize:   10 * 1024 * 1024, // 10MB
+		imageService: imageService,
+		jobs:         jobs,
+		catalog:      catalog,
+		janitor:      janitor,
+		uploads:      uploads,
+		compressed:   compressed,
+		maxFileSize:  10 * 1024 * 1024, // 10MB
 	}
 }
 
@@ -44,6 +54,16 @@ func (h *ImageHandler) UploadImage(c *gin.Context) {
 		return
 	}
 
+	// 获取所有者与过期时间
+	meta, err := h.parseRequestMeta(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.LegacyErrorResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
 	// 获取上传的文件
 	file, fileHeader, err := c.Request.FormFile("image")
 	if err != nil {
@@ -73,13 +93,19 @@ func (h *ImageHandler) UploadImage(c *gin.Context) {
 		return
 	}
 
-	// 生成唯一文件名
-	timestamp := time.Now().Unix()
-	originalFilename := fmt.Sprintf("%d_%s", timestamp, fileHeader.Filename)
-	inputPath := filepath.Join(h.uploadDir, originalFilename)
+	// 根据文件内容校验格式
+	data, err := h.readUpload(fileHeader.Filename, fileHeader.Header.Get("Content-Type"), file)
+	if err != nil {
+		c.JSON(uploadErrorStatus(err), utils.LegacyErrorResponse{
+			Success: false,
+			Message: err.Error(),
+			Code:    errorCode(err),
+		})
+		return
+	}
 
-	// 保存上传的文件
-	err = c.SaveUploadedFile(fileHeader, inputPath)
+	// 以随机 ID 登记上传，相同内容只保存一份文件
+	upload, err := h.saveUpload(c.Request.Context(), meta, fileHeader.Filename, fileHeader.Header.Get("Content-Type"), data)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.LegacyErrorResponse{
 			Success: false,
@@ -93,12 +119,14 @@ func (h *ImageHandler) UploadImage(c *gin.Context) {
 		Success: true,
 		Message: "图片上传成功",
 		Data: gin.H{
-			"fileName":     fileHeader.Filename,
-			"filePath":     originalFilename,
+			"fileName":     upload.Name,
+			"id":           upload.ID,
+			"filePath":     upload.Path,
 			"fileSize":     fileHeader.Size,
 			"fileType":     fileHeader.Header.Get("Content-Type"),
-			"originalName": fileHeader.Filename,
+			"originalName": upload.Name,
 			"uploadTime":   time.Now().Unix(),
+			"duplicate":    upload.Existing,
 		},
 	})
 }
@@ -106,21 +134,22 @@ func (h *ImageHandler) UploadImage(c *gin.Context) {
 // CompressImage 压缩已上传的图片
 func (h *ImageHandler) CompressImage(c *gin.Context) {
 	// 获取要压缩的文件名
-	filename := c.PostForm("filename")
-	if filename == "" {
+	filename, err := resolveFilename(c.PostForm("filename"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, utils.LegacyErrorResponse{
 			Success: false,
-			Message: "请提供要压缩的文件名",
+			Message: err.Error(),
 		})
 		return
 	}
 
-	// 构建文件路径
-	inputPath := filepath.Join(h.uploadDir, filename)
-
-	// 检查文件是否存在
-	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, utils.LegacyErrorResponse{
+	// 检查请求所有者的上传记录与文件是否存在
+	upload, err := h.ownedUpload(requestOwner(c), filename)
+	if err == nil {
+		_, err = h.uploads.Stat(c.Request.Context(), upload.Key)
+	}
+	if err != nil {
+		c.JSON(storageErrorStatus(err), utils.LegacyErrorResponse{
 			Success: false,
 			Message: "要压缩的文件不存在",
 		})
@@ -137,38 +166,65 @@ func (h *ImageHandler) CompressImage(c *gin.Context) {
 	}
 
 	// 获取压缩选项
-	options := h.parseCompressionOptions(c)
+	options, err := h.parseCompressionOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.LegacyErrorResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
 
-	// 生成压缩后文件名
-	compressedFilename := models.GenerateUniqueFilename(filename)
-	outputPath := filepath.Join(h.compressedDir, compressedFilename)
+	// 获取所有者与过期时间
+	meta, err := h.parseRequestMeta(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.LegacyErrorResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
 
-	// 压缩图片
-	result, err := h.imageService.CompressImage(inputPath, outputPath, options)
+	// 压缩图片，相同文件与选项直接返回已有结果
+	result, err := h.imageService.CompressCached(c.Request.Context(), upload.Key, options)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.LegacyErrorResponse{
+		c.JSON(compressErrorStatus(err), utils.LegacyErrorResponse{
 			Success: false,
 			Message: fmt.Sprintf("图片压缩失败: %v", err),
+			Code:    errorCode(err),
 		})
 		return
 	}
+	h.janitor.Touch(models.AreaUploads, upload.Key)
+	h.recordResult(meta, upload.Path(), upload.Name, result, options)
 
 	// 返回压缩结果
+	response := gin.H{
+		"originalFile":       upload.Path(),
+		"compressedFile":     result.Filename,
+		"displayName":        result.DisplayName,
+		"originalSize":       result.OriginalSize,
+		"compressedSize":     result.CompressedSize,
+		"compressionRatio":   result.Ratio,
+		"quality":            result.Quality,
+		"iterations":         result.Iterations,
+		"keptOriginal":       result.KeptOriginal,
+		"largerThanOriginal": result.LargerThanOriginal,
+		"cached":             result.Cached,
+		"hashes":             result.Hashes,
+		"metrics":            result.Metrics,
+		"width":              options.Width,
+		"height":             options.Height,
+		"originalUrl":        h.uploads.URL(upload.Path()),
+		"compressedUrl":      h.compressed.URL(result.Filename),
+	}
+	if palette := h.outputPalette(c.Request.Context(), meta, result.Filename); palette != nil {
+		response["palette"] = palette
+	}
 	c.JSON(http.StatusOK, utils.LegacySuccessResponse{
 		Success: true,
 		Message: "图片压缩成功",
-		Data: gin.H{
-			"originalFile":     filename,
-			"compressedFile":   compressedFilename,
-			"originalSize":     result.OriginalSize,
-			"compressedSize":   result.CompressedSize,
-			"compressionRatio": result.Ratio,
-			"quality":          options.Quality,
-			"width":            options.Width,
-			"height":           options.Height,
-			"originalUrl":      fmt.Sprintf("/api/uploads/%s", filename),
-			"compressedUrl":    fmt.Sprintf("/api/static/%s", compressedFilename),
-		},
+		Data:    response,
 	})
 }
 
@@ -213,55 +269,88 @@ func (h *ImageHandler) UploadAndCompress(c *gin.Context) {
 		return
 	}
 
-	// 生成唯一文件名
-	timestamp := time.Now().Unix()
-	originalFilename := fmt.Sprintf("%d_%s", timestamp, fileHeader.Filename)
-	inputPath := filepath.Join(h.uploadDir, originalFilename)
-
-	// 保存上传的文件
-	err = c.SaveUploadedFile(fileHeader, inputPath)
+	// 根据文件内容校验格式
+	data, err := h.readUpload(fileHeader.Filename, fileHeader.Header.Get("Content-Type"), file)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.LegacyErrorResponse{
+		c.JSON(uploadErrorStatus(err), utils.LegacyErrorResponse{
 			Success: false,
-			Message: "保存文件失败",
+			Message: err.Error(),
+			Code:    errorCode(err),
 		})
 		return
 	}
 
 	// 获取压缩选项
-	options := h.parseCompressionOptions(c)
+	options, err := h.parseCompressionOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.LegacyErrorResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// 获取所有者与过期时间
+	meta, err := h.parseRequestMeta(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.LegacyErrorResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
 
-	// 生成压缩后文件名
-	compressedFilename := models.GenerateUniqueFilename(originalFilename)
-	outputPath := filepath.Join(h.compressedDir, compressedFilename)
+	// 以随机 ID 登记上传，相同内容只保存一份文件
+	upload, err := h.saveUpload(c.Request.Context(), meta, fileHeader.Filename, fileHeader.Header.Get("Content-Type"), data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.LegacyErrorResponse{
+			Success: false,
+			Message: "保存文件失败",
+		})
+		return
+	}
 
-	// 压缩图片
-	result, err := h.imageService.CompressImage(inputPath, outputPath, options)
+	// 压缩图片，相同文件与选项直接返回已有结果
+	result, err := h.imageService.CompressCached(c.Request.Context(), upload.Key, options)
 	if err != nil {
 		// 清理上传的文件
-		os.Remove(inputPath)
-		c.JSON(http.StatusInternalServerError, utils.LegacyErrorResponse{
+		h.discardUpload(upload)
+		c.JSON(compressErrorStatus(err), utils.LegacyErrorResponse{
 			Success: false,
 			Message: fmt.Sprintf("图片压缩失败: %v", err),
+			Code:    errorCode(err),
 		})
 		return
 	}
 
-	// 清理原始上传文件（可选，这里保留以供对比）
-	// os.Remove(inputPath)
+	h.recordResult(meta, upload.Path, upload.Name, result, options)
+
+	// 原始上传文件保留以供对比，过期后由清理任务删除
 
 	// 为前端兼容性，返回期望的格式
+	response := gin.H{
+		"fileName":           upload.Name,
+		"displayName":        result.DisplayName,
+		"filePath":           result.Filename,
+		"fileSize":           result.CompressedSize,
+		"fileType":           fileHeader.Header.Get("Content-Type"),
+		"originalSize":       result.OriginalSize,
+		"compressionRatio":   result.Ratio,
+		"quality":            result.Quality,
+		"iterations":         result.Iterations,
+		"keptOriginal":       result.KeptOriginal,
+		"largerThanOriginal": result.LargerThanOriginal,
+		"cached":             result.Cached,
+		"hashes":             result.Hashes,
+		"metrics":            result.Metrics,
+	}
+	if palette := h.outputPalette(c.Request.Context(), meta, result.Filename); palette != nil {
+		response["palette"] = palette
+	}
 	c.JSON(http.StatusOK, utils.LegacySuccessResponse{
 		Success: true,
 		Message: "图片上传成功",
-		Data: gin.H{
-			"fileName":         fileHeader.Filename,
-			"filePath":         compressedFilename,
-			"fileSize":         result.CompressedSize,
-			"fileType":         fileHeader.Header.Get("Content-Type"),
-			"originalSize":     result.OriginalSize,
-			"compressionRatio": result.Ratio,
-		},
+		Data:    response,
 	})
 }
 
@@ -270,111 +359,452 @@ func (h *ImageHandler) GetSupportedFormats(c *gin.Context) {
 	formats := h.imageService.GetSupportedFormats()
 	c.JSON(http.StatusOK, gin.H{
 		"supportedFormats": formats,
+		"formats":          h.imageService.GetFormatDetails(),
 		"maxFileSize":      fmt.Sprintf("%d MB", h.maxFileSize/(1024*1024)),
 	})
 }
 
 // DownloadCompressed 下载压缩后的图片
 func (h *ImageHandler) DownloadCompressed(c *gin.Context) {
-	filename := c.Param("filename")
-	if filename == "" {
+	filename, err := resolveFilename(c.Param("filename"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, utils.ResponseError{
-			Error: "缺少文件名",
+			Error: err.Error(),
 		})
 		return
 	}
 
-	filePath := filepath.Join(h.compressedDir, filename)
-
-	// 检查文件是否存在
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	// 图片需在请求所有者的目录中有记录，批量处理生成的 ZIP 除外
+	displayName := filename
+	if record, err := h.catalog.Get(requestOwner(c), filename); err == nil {
+		displayName = record.DisplayName
+	} else if !isBatchArchive(filename) {
 		c.JSON(http.StatusNotFound, utils.ResponseError{
 			Error: "文件不存在",
 		})
 		return
 	}
 
-	// 设置响应头
-	c.Header("Content-Description", "File Transfer")
-	c.Header("Content-Transfer-Encoding", "binary")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	c.Header("Content-Type", "application/octet-stream")
+	// 检查文件是否存在
+	info, err := h.compressed.Stat(c.Request.Context(), filename)
+	if err != nil {
+		c.JSON(storageErrorStatus(err), utils.ResponseError{
+			Error: "文件不存在",
+		})
+		return
+	}
+	reader, err := h.compressed.Get(c.Request.Context(), filename)
+	if err != nil {
+		c.JSON(storageErrorStatus(err), utils.ResponseError{
+			Error: "读取文件失败",
+		})
+		return
+	}
+	defer reader.Close()
+	h.janitor.Touch(models.AreaCompressed, filename)
 
 	// 发送文件
-	c.File(filePath)
+	// 非 ASCII 文件名按 RFC 2231 编码
+	disposition := mime.FormatMediaType("attachment", map[string]string{
+		"filename": displayName,
+	})
+	if disposition == "" {
+		disposition = mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+	}
+	c.DataFromReader(http.StatusOK, info.Size, "application/octet-stream", reader, map[string]string{
+		"Content-Description":       "File Transfer",
+		"Content-Transfer-Encoding": "binary",
+		"Content-Disposition":       disposition,
+	})
 }
 
-// ListCompressedImages 列出所有压缩的图片
+// ServeUpload 访问原始上传文件，路径为上传时返回的 <id><扩展名>
+func (h *ImageHandler) ServeUpload(c *gin.Context) {
+	filename, err := resolveFilename(strings.TrimPrefix(c.Param("filepath"), "/"))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	upload, err := h.uploadRecord(filename)
+	if err != nil {
+		c.Status(storageErrorStatus(err))
+		return
+	}
+	h.serveObject(c, models.AreaUploads, h.uploads, upload.Key)
+}
+
+// ServeCompressed 访问压缩后的文件
+func (h *ImageHandler) ServeCompressed(c *gin.Context) {
+	key, err := resolveFilename(strings.TrimPrefix(c.Param("filepath"), "/"))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	h.serveObject(c, models.AreaCompressed, h.compressed, key)
+}
+
+// serveObject 以内联方式返回存储中的文件
+func (h *ImageHandler) serveObject(c *gin.Context, area string, store storage.Storage, key string) {
+	info, err := store.Stat(c.Request.Context(), key)
+	if err != nil {
+		c.Status(storageErrorStatus(err))
+		return
+	}
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	headers := map[string]string{}
+	if !info.ModTime.IsZero() {
+		headers["Last-Modified"] = info.ModTime.UTC().Format(http.TimeFormat)
+	}
+
+	// 支持按范围读取的存储响应单个字节范围，多个范围时返回完整文件
+	status, offset, length := http.StatusOK, int64(0), info.Size
+	ranger, canRange := store.(storage.RangeReader)
+	if canRange {
+		headers["Accept-Ranges"] = "bytes"
+		if value := c.GetHeader("Range"); value != "" {
+			start, n, ok := parseByteRange(value, info.Size)
+			if !ok {
+				c.Header("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+				c.Status(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			if n >= 0 {
+				status, offset, length = http.StatusPartialContent, start, n
+				headers["Content-Range"] = fmt.Sprintf("bytes %d-%d/%d", start, start+n-1, info.Size)
+			}
+		}
+	}
+
+	var reader io.ReadCloser
+	if status == http.StatusPartialContent {
+		reader, err = ranger.GetRange(c.Request.Context(), key, offset, length)
+	} else {
+		reader, err = store.Get(c.Request.Context(), key)
+	}
+	if err != nil {
+		c.Status(storageErrorStatus(err))
+		return
+	}
+	defer reader.Close()
+	h.janitor.Touch(area, key)
+	c.DataFromReader(status, length, contentType, reader, headers)
+}
+
+// parseByteRange 解析 Range 请求头中的单个字节范围，返回起始位置与长度
+// 多个范围或非 bytes 单位时返回长度 -1 表示忽略，范围无法满足时 ok 为 false
+func parseByteRange(value string, size int64) (start, length int64, ok bool) {
+	spec, found := strings.CutPrefix(value, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, -1, true
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false
+	}
+	if first == "" {
+		// 后缀范围，如 bytes=-500 表示最后 500 字节
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 || size == 0 {
+			return 0, 0, false
+		}
+		n = min(n, size)
+		return size - n, n, true
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false
+		}
+		end = min(end, size-1)
+	}
+	return start, end - start + 1, true
+}
+
+// ListCompressedImages 列出请求所有者（X-Owner 请求头）的压缩图片
+// 支持 format、q（文件名）、uploadId 筛选，sort（createdAt/size/ratio/name）与 order（asc/desc）排序，page、pageSize 分页
 func (h *ImageHandler) ListCompressedImages(c *gin.Context) {
-	files, err := os.ReadDir(h.compressedDir)
+	query, err := parseCatalogQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	records, total, err := h.catalog.List(query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.ResponseError{
-			Error: "读取压缩文件目录失败",
+			Error: "读取图片目录失败",
 		})
 		return
 	}
 
-	var images []map[string]interface{}
-	for _, file := range files {
-		if !file.IsDir() && h.imageService.ValidateImageFormat(file.Name()) {
-			info, _ := file.Info()
-			images = append(images, map[string]interface{}{
-				"filename":    file.Name(),
-				"size":        info.Size(),
-				"modTime":     info.ModTime(),
-				"downloadUrl": fmt.Sprintf("/api/v1/images/download/%s", file.Name()),
-			})
-		}
+	images := make([]gin.H, 0, len(records))
+	for _, record := range records {
+		images = append(images, gin.H{
+			"filename":    record.Filename,
+			"displayName": record.DisplayName,
+			"size":        record.CompressedSize,
+			"modTime":     record.CreatedAt,
+			"downloadUrl": fmt.Sprintf("/api/v1/images/download/%s", record.Filename),
+			"record":      record,
+		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"images": images,
-		"count":  len(images),
+		"images":   images,
+		"count":    len(images),
+		"total":    total,
+		"page":     query.Page,
+		"pageSize": query.PageSize,
 	})
 }
 
-// DeleteCompressedImage 删除压缩的图片
+// DeleteCompressedImage 删除请求所有者（X-Owner 请求头）的目录记录
+// 压缩结果可能被多个所有者共用，没有其他记录引用时才删除文件；其他所有者的记录视为不存在
 func (h *ImageHandler) DeleteCompressedImage(c *gin.Context) {
-	filename := c.Param("filename")
-	if filename == "" {
+	filename, err := resolveFilename(c.Param("filename"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, utils.ResponseError{
-			Error: "缺少文件名",
+			Error: err.Error(),
 		})
 		return
 	}
 
-	filePath := filepath.Join(h.compressedDir, filename)
-
-	// 检查文件是否存在
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	remaining, err := h.catalog.Delete(requestOwner(c), filename)
+	if errors.Is(err, models.ErrRecordNotFound) {
 		c.JSON(http.StatusNotFound, utils.ResponseError{
 			Error: "文件不存在",
 		})
 		return
 	}
-
-	// 删除文件
-	err := os.Remove(filePath)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.ResponseError{
-			Error: "删除文件失败",
+			Error: "删除图片记录失败",
 		})
 		return
 	}
 
+	// 最后一条记录删除后删除文件，文件已不存在时忽略
+	if remaining == 0 {
+		if err := h.compressed.Delete(c.Request.Context(), filename); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			c.JSON(storageErrorStatus(err), utils.ResponseError{
+				Error: "删除文件失败",
+			})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, utils.ResponseSuccess{
 		Message: "文件删除成功",
 		Data:    gin.H{"filename": filename},
 	})
 }
 
+// readUpload 读取上传内容并根据文件头校验真实格式
+func (h *ImageHandler) readUpload(filename, contentType string, r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, h.maxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败")
+	}
+	if int64(len(data)) > h.maxFileSize {
+		return nil, fmt.Errorf("文件大小超过限制 %d MB", h.maxFileSize/(1024*1024))
+	}
+	if _, err := h.imageService.ValidateUpload(filename, contentType, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// resolveFilename 校验客户端传入的存储文件名，所有文件接口统一使用
+// 存储文件名由服务端生成，拒绝空值、路径分隔符、控制字符与隐藏文件
+func resolveFilename(filename string) (string, error) {
+	if filename == "" {
+		return "", errors.New("缺少文件名")
+	}
+	if err := storage.ValidateKey(filename); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// storageErrorStatus 根据存储错误类型确定 HTTP 状态码
+func storageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrInvalidKey):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorCode 返回上传内容校验错误的错误码
+func errorCode(err error) string {
+	var validationErr *models.ImageValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Code
+	}
+	return ""
+}
+
+// uploadErrorStatus 根据上传错误类型确定 HTTP 状态码
+func uploadErrorStatus(err error) int {
+	if errorCode(err) == models.CodeUnsupportedContent {
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusBadRequest
+}
+
+// compressErrorStatus 根据压缩错误类型确定 HTTP 状态码
+func compressErrorStatus(err error) int {
+	switch {
+	case errorCode(err) != "":
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, models.ErrTargetSizeUnreachable), errors.Is(err, models.ErrQualityBelowThreshold):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, models.ErrInvalidInput):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// storeSource 带名称的存储，用于在多个存储中查找文件
+type storeSource struct {
+	name  string
+	store storage.Storage
+	key   func(filename string) (string, error) // 将请求中的文件名转换为存储中的文件名
+}
+
+// imageSources 根据 source 查询参数确定查找已存储图片的顺序，只能找到所有者自己的压缩结果与上传文件
+// 默认先在压缩目录中查找，再查找上传目录
+func (h *ImageHandler) imageSources(owner, source string) ([]storeSource, error) {
+	compressed := storeSource{name: "compressed", store: h.compressed, key: func(filename string) (string, error) {
+		if _, err := h.catalog.Get(owner, filename); err != nil {
+			return "", storage.ErrNotFound
+		}
+		return filename, nil
+	}}
+	uploads := storeSource{name: "uploads", store: h.uploads, key: func(filename string) (string, error) {
+		record, err := h.ownedUpload(owner, filename)
+		return record.Key, err
+	}}
+	switch source {
+	case "":
+		return []storeSource{compressed, uploads}, nil
+	case "compressed":
+		return []storeSource{compressed}, nil
+	case "uploads":
+		return []storeSource{uploads}, nil
+	}
+	return nil, errors.New("source 只能是 uploads 或 compressed")
+}
+
+// GetImageInfo 获取请求所有者已存储图片的元信息
+// 默认先在压缩目录中查找，再查找上传目录，可通过 source=uploads|compressed 指定
+func (h *ImageHandler) GetImageInfo(c *gin.Context) {
+	filename, err := resolveFilename(c.Param("filename"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	sources, err := h.imageSources(requestOwner(c), c.Query("source"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	for _, source := range sources {
+		key, err := source.key(filename)
+		if err != nil {
+			continue
+		}
+		file, err := source.store.Get(c.Request.Context(), key)
+		if err != nil {
+			continue
+		}
+		info, err := h.imageService.Inspect(file)
+		file.Close()
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, utils.ResponseError{
+				Error: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, utils.ResponseSuccess{
+			Message: "获取图片信息成功",
+			Data: gin.H{
+				"filename": filename,
+				"source":   source.name,
+				"info":     info,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusNotFound, utils.ResponseError{
+		Error: "文件不存在",
+	})
+}
+
+// InspectUpload 获取上传图片的元信息，不保存文件
+func (h *ImageHandler) InspectUpload(c *gin.Context) {
+	file, fileHeader, err := c.Request.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{
+			Error: "请选择要上传的图片文件",
+		})
+		return
+	}
+	defer file.Close()
+
+	if fileHeader.Size > h.maxFileSize {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{
+			Error: fmt.Sprintf("文件大小超过限制 %d MB", h.maxFileSize/(1024*1024)),
+		})
+		return
+	}
+
+	info, err := h.imageService.Inspect(file)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, utils.ResponseError{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.ResponseSuccess{
+		Message: "获取图片信息成功",
+		Data: gin.H{
+			"filename": utils.SanitizeFilename(fileHeader.Filename),
+			"info":     info,
+		},
+	})
+}
+
 // parseCompressionOptions 解析压缩选项
-func (h *ImageHandler) parseCompressionOptions(c *gin.Context) models.CompressionOption {
+func (h *ImageHandler) parseCompressionOptions(c *gin.Context) (models.CompressionOption, error) {
 	options := models.CompressionOption{
 		Quality:    85,   // 默认质量
 		Width:      0,    // 默认不调整宽度
 		Height:     0,    // 默认不调整高度
 		KeepAspect: true, // 默认保持宽高比
+		Dither:     true, // 默认量化时使用抖动
 	}
 
 	// 解析质量参数
@@ -405,5 +835,137 @@ func (h *ImageHandler) parseCompressionOptions(c *gin.Context) models.Compressio
 		}
 	}
 
-	return options
+	// 解析输出格式
+	format, err := models.NormalizeOutputFormat(c.PostForm("format"))
+	if err != nil {
+		return options, err
+	}
+	options.OutputFormat = format
+
+	// 解析铺底颜色
+	if background := c.PostForm("background"); background != "" {
+		if _, err := models.ParseHexColor(background); err != nil {
+			return options, err
+		}
+		options.Background = background
+	}
+
+	// 解析目标文件大小
+	if targetSizeStr := c.PostForm("targetSize"); targetSizeStr != "" {
+		targetSize, err := utils.ParseByteSize(targetSizeStr)
+		if err != nil {
+			return options, err
+		}
+		options.TargetSize = targetSize
+	}
+
+	// 解析是否允许缩小尺寸以满足目标大小
+	if downscaleStr := c.PostForm("allowDownscale"); downscaleStr != "" {
+		if allowDownscale, err := strconv.ParseBool(downscaleStr); err == nil {
+			options.AllowDownscale = allowDownscale
+		}
+	}
+
+	// 解析 PNG 压缩级别
+	if pngLevel := c.PostForm("pngLevel"); pngLevel != "" {
+		if _, err := models.ParsePNGCompressionLevel(pngLevel); err != nil {
+			return options, err
+		}
+		options.PNGCompression = pngLevel
+	}
+
+	// 解析调色板颜色数量
+	if colorsStr := c.PostForm("colors"); colorsStr != "" {
+		colors, err := strconv.Atoi(colorsStr)
+		if err != nil || !models.ValidatePaletteColors(colors) {
+			return options, fmt.Errorf("调色板颜色数量必须在 2-256 之间")
+		}
+		options.Colors = colors
+	}
+
+	// 解析是否抖动
+	if ditherStr := c.PostForm("dither"); ditherStr != "" {
+		if dither, err := strconv.ParseBool(ditherStr); err == nil {
+			options.Dither = dither
+		}
+	}
+
+	// 解析元数据处理模式
+	metadata, err := models.ParseMetadataMode(c.PostForm("metadata"))
+	if err != nil {
+		return options, err
+	}
+	options.Metadata = metadata
+
+	// 解析变换操作，格式为 JSON 数组
+	if transformsStr := c.PostForm("transforms"); transformsStr != "" {
+		var transforms []models.TransformOp
+		if err := json.Unmarshal([]byte(transformsStr), &transforms); err != nil {
+			return options, fmt.Errorf("变换操作格式错误: %v", err)
+		}
+		if err := models.ValidateTransforms(transforms); err != nil {
+			return options, err
+		}
+		options.Transforms = transforms
+	}
+
+	// 解析缩放模式与相关参数
+	options.Mode = c.PostForm("mode")
+	options.Anchor = c.PostForm("anchor")
+	options.Filter = c.PostForm("filter")
+	if percentageStr := c.PostForm("percentage"); percentageStr != "" {
+		percentage, err := strconv.ParseFloat(percentageStr, 64)
+		if err != nil {
+			return options, fmt.Errorf("无效的缩放百分比: %s", percentageStr)
+		}
+		options.Percentage = percentage
+	}
+	if megapixelsStr := c.PostForm("maxMegapixels"); megapixelsStr != "" {
+		megapixels, err := strconv.ParseFloat(megapixelsStr, 64)
+		if err != nil {
+			return options, fmt.Errorf("无效的最大像素数: %s", megapixelsStr)
+		}
+		options.MaxMegapixels = megapixels
+	}
+	if noUpscaleStr := c.PostForm("noUpscale"); noUpscaleStr != "" {
+		if noUpscale, err := strconv.ParseBool(noUpscaleStr); err == nil {
+			options.NoUpscale = noUpscale
+		}
+	}
+	if err := models.ValidateResizeOptions(options); err != nil {
+		return options, err
+	}
+
+	// 解析滤镜与调整，格式为 JSON 数组
+	if filtersStr := c.PostForm("filters"); filtersStr != "" {
+		var filters []models.FilterOp
+		if err := json.Unmarshal([]byte(filtersStr), &filters); err != nil {
+			return options, fmt.Errorf("滤镜格式错误: %v", err)
+		}
+		if err := models.ValidateFilters(filters); err != nil {
+			return options, err
+		}
+		options.Filters = filters
+	}
+
+	// 解析最低 SSIM
+	if minSSIMStr := c.PostForm("minSSIM"); minSSIMStr != "" {
+		minSSIM, err := strconv.ParseFloat(minSSIMStr, 64)
+		if err != nil {
+			return options, fmt.Errorf("无效的最低 SSIM: %s", minSSIMStr)
+		}
+		if err := models.ValidateMinSSIM(minSSIM); err != nil {
+			return options, err
+		}
+		options.MinSSIM = minSSIM
+	}
+
+	// 解析水印
+	watermark, err := h.parseWatermark(c)
+	if err != nil {
+		return options, err
+	}
+	options.Watermark = watermark
+
+	return options, nil
 }
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		valid    bool
+	}{
+		{"压缩结果", "0123456789abcdef0123456789abcdef_compressed.jpg", true},
+		{"中文文件名", "照片.png", true},
+		{"空文件名", "", false},
+		{"上级目录", "..", false},
+		{"相对路径", "../../etc/passwd", false},
+		{"绝对路径", "/etc/passwd", false},
+		{"Windows 路径", `C:\Windows\win.ini`, false},
+		{"隐藏文件", ".catalog.json", false},
+		{"NUL 字符", "a.jpg\x00.png", false},
+		{"RTL 覆盖字符", "a\u202egpj.exe", false},
+		{"超过 255 字节", strings.Repeat("a", 252) + ".jpg", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveFilename(tt.filename)
+			if tt.valid {
+				if err != nil || got != tt.filename {
+					t.Errorf("resolveFilename(%q) = %q, %v, want %q, nil", tt.filename, got, err, tt.filename)
+				}
+				return
+			}
+			if err == nil {
+				t.Errorf("resolveFilename(%q) = %q, want error", tt.filename, got)
+			}
+		})
+	}
+}
+
+func TestParseByteRange(t *testing.T) {
+	tests := []struct {
+		value         string
+		start, length int64
+		ok            bool
+	}{
+		{"bytes=0-99", 0, 100, true},
+		{"bytes=100-", 100, 900, true},
+		{"bytes=-100", 900, 100, true},
+		{"bytes=-5000", 0, 1000, true},
+		{"bytes=990-2000", 990, 10, true},
+		{"bytes=0-1,5-9", 0, -1, true},
+		{"items=0-1", 0, -1, true},
+		{"bytes=1000-", 0, 0, false},
+		{"bytes=5-1", 0, 0, false},
+		{"bytes=-0", 0, 0, false},
+		{"bytes=abc", 0, 0, false},
+		{"bytes=a-b", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, length, ok := parseByteRange(tt.value, 1000)
+		if ok != tt.ok || (ok && (start != tt.start || length != tt.length)) {
+			t.Errorf("parseByteRange(%q) = %d, %d, %v, want %d, %d, %v", tt.value, start, length, ok, tt.start, tt.length, tt.ok)
+		}
+	}
+}
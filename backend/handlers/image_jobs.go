@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"mini-toolbox/models"
+	"mini-toolbox/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobSubmission 提交异步任务时单个文件的结果
+type JobSubmission struct {
+	Filename  string `json:"filename"`            // 原始文件名
+	JobID     string `json:"jobId,omitempty"`     // 任务 ID
+	StatusURL string `json:"statusUrl,omitempty"` // 任务状态查询地址
+	Error     string `json:"error,omitempty"`     // 提交失败原因
+	Code      string `json:"code,omitempty"`      // 内容校验失败时的错误码
+}
+
+// SubmitCompressJobs 上传图片并提交异步压缩任务
+// 与批量接口一样支持多个 image 字段或一个 archive 压缩包，每个文件对应一个任务
+func (h *ImageHandler) SubmitCompressJobs(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBatchRequestSize)
+	if err := c.Request.ParseMultipartForm(h.maxFileSize); err != nil {
+		c.JSON(http.StatusBadRequest, utils.LegacyErrorResponse{
+			Success: false,
+			Message: "文件太大或请求格式错误",
+		})
+		return
+	}
+	defer c.Request.MultipartForm.RemoveAll()
+
+	options, err := h.parseCompressionOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.LegacyErrorResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// 获取所有者与过期时间
+	meta, err := h.parseRequestMeta(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.LegacyErrorResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	sources, cleanup, err := h.collectBatchSources(c.Request.MultipartForm)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.LegacyErrorResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	defer cleanup()
+
+	batchID := ""
+	if len(sources) > 1 {
+		batchID = utils.NewID()
+	}
+
+	submissions := make([]JobSubmission, 0, len(sources))
+	accepted := 0
+	for _, source := range sources {
+		submission := h.submitCompressJob(c.Request.Context(), meta, batchID, source, options)
+		if submission.JobID != "" {
+			accepted++
+		}
+		submissions = append(submissions, submission)
+	}
+
+	status := http.StatusAccepted
+	if accepted == 0 {
+		status = http.StatusServiceUnavailable
+		for _, submission := range submissions {
+			if submission.Error != models.ErrQueueFull.Error() {
+				status = http.StatusUnprocessableEntity
+				break
+			}
+		}
+	}
+
+	c.JSON(status, utils.LegacySuccessResponse{
+		Success: accepted > 0,
+		Message: fmt.Sprintf("已提交 %d 个任务，失败 %d 个", accepted, len(submissions)-accepted),
+		Data: gin.H{
+			"batchId": batchID,
+			"jobs":    submissions,
+		},
+	})
+}
+
+// submitCompressJob 保存单个文件并提交压缩任务
+func (h *ImageHandler) submitCompressJob(ctx context.Context, meta requestMeta, batchID string, source batchSource, options models.CompressionOption) JobSubmission {
+	submission := JobSubmission{Filename: source.name}
+
+	jobID := utils.NewID()
+	upload, err := h.saveBatchItem(ctx, meta, source)
+	if err != nil {
+		submission.Error = err.Error()
+		submission.Code = errorCode(err)
+		return submission
+	}
+
+	job, err := h.jobs.Submit(models.JobSpec{
+		ID:       jobID,
+		BatchID:  batchID,
+		Type:     "compress",
+		Filename: source.name,
+		Task: func(ctx context.Context) (interface{}, error) {
+			result, err := h.imageService.CompressCached(ctx, upload.Key, options)
+			if err != nil {
+				if !errors.Is(err, context.Canceled) {
+					h.discardUpload(upload)
+				}
+				return nil, err
+			}
+			h.recordResult(meta, upload.Path, upload.Name, result, options)
+			return result, nil
+		},
+		// 压缩结果按内容寻址，可能与其他任务共用，取消时只清理本次保存的上传文件
+		OnCancel: func() {
+			h.discardUpload(upload)
+		},
+	})
+	if err != nil {
+		h.discardUpload(upload)
+		submission.Error = err.Error()
+		return submission
+	}
+
+	submission.JobID = job.ID
+	submission.StatusURL = fmt.Sprintf("/api/v1/jobs/%s", job.ID)
+	return submission
+}
+
+// discardUpload 删除本次请求的上传记录，文件不再被其他上传引用时一并删除
+func (h *ImageHandler) discardUpload(upload *models.StoredUpload) {
+	remaining, err := h.catalog.ReleaseUpload(upload.ID)
+	if err == nil && remaining == 0 {
+		h.uploads.Delete(context.Background(), upload.Key)
+	}
+}
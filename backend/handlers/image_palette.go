@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"mini-toolbox/models"
+	"mini-toolbox/storage"
+	"mini-toolbox/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetImagePalette 提取请求所有者已存储图片的主色与平均色
+// colors 为主色数量（默认 5，最多 16），默认先在压缩目录中查找，可通过 source=uploads|compressed 指定
+func (h *ImageHandler) GetImagePalette(c *gin.Context) {
+	filename, err := resolveFilename(c.Param("filename"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	size := models.DefaultPaletteSize
+	if value := c.Query("colors"); value != "" {
+		if size, err = strconv.Atoi(value); err == nil {
+			err = models.ValidatePaletteSize(size)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.ResponseError{
+				Error: "主色数量必须在 1-16 之间",
+			})
+			return
+		}
+	}
+
+	sources, err := h.imageSources(requestOwner(c), c.Query("source"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	for _, source := range sources {
+		key, err := source.key(filename)
+		if err != nil {
+			continue
+		}
+		data, err := storage.ReadAll(ctx, source.store, key)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			c.JSON(storageErrorStatus(err), utils.ResponseError{
+				Error: "读取文件失败",
+			})
+			return
+		}
+		palette, err := h.imageService.ExtractPalette(data, size)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, utils.ResponseError{
+				Error: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, utils.ResponseSuccess{
+			Message: "提取主色成功",
+			Data: gin.H{
+				"filename": filename,
+				"source":   source.name,
+				"palette":  palette,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusNotFound, utils.ResponseError{
+		Error: "文件不存在",
+	})
+}
+
+// outputPalette 按请求提取压缩结果的主色，未请求或提取失败时返回 nil
+func (h *ImageHandler) outputPalette(ctx context.Context, meta requestMeta, filename string) *models.ColorPalette {
+	if meta.palette == 0 {
+		return nil
+	}
+	data, err := storage.ReadAll(ctx, h.compressed, filename)
+	if err != nil {
+		log.Printf("读取压缩结果失败: %v", err)
+		return nil
+	}
+	palette, err := h.imageService.ExtractPalette(data, meta.palette)
+	if err != nil {
+		log.Printf("提取主色失败: %v", err)
+		return nil
+	}
+	return palette
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mini-toolbox/models"
+	"mini-toolbox/storage"
+	"mini-toolbox/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSimilarThreshold = 10 // 默认汉明距离阈值
+	defaultSimilarLimit     = 20 // 默认返回数量
+)
+
+// errNoComparisonImage 请求中没有提供待比较的图片
+var errNoComparisonImage = errors.New("请上传图片或指定已存储的文件名")
+
+// comparisonImage 用于比较的图片，来自本次上传或已存储的文件
+type comparisonImage struct {
+	Name   string `json:"name"`   // 上传文件名或存储文件名
+	Source string `json:"source"` // upload、compressed 或 uploads
+	data   []byte
+}
+
+// loadComparisonImage 读取 fileField 对应的上传文件，未上传时读取 nameField 指定的请求所有者已存储的文件
+// 已存储文件先在压缩目录中查找，再查找上传目录；上传文件不会保存
+func (h *ImageHandler) loadComparisonImage(c *gin.Context, fileField, nameField string) (*comparisonImage, int, error) {
+	if file, fileHeader, err := c.Request.FormFile(fileField); err == nil {
+		defer file.Close()
+		data, err := h.readUpload(fileHeader.Filename, fileHeader.Header.Get("Content-Type"), file)
+		if err != nil {
+			return nil, uploadErrorStatus(err), err
+		}
+		return &comparisonImage{Name: utils.SanitizeFilename(fileHeader.Filename), Source: "upload", data: data}, http.StatusOK, nil
+	}
+
+	name := strings.TrimSpace(c.PostForm(nameField))
+	if name == "" {
+		return nil, http.StatusBadRequest, errNoComparisonImage
+	}
+	filename, err := resolveFilename(name)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	ctx := c.Request.Context()
+	sources, _ := h.imageSources(requestOwner(c), "")
+	for _, source := range sources {
+		key, err := source.key(filename)
+		if err != nil {
+			continue
+		}
+		data, err := storage.ReadAll(ctx, source.store, key)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, storageErrorStatus(err), fmt.Errorf("读取文件失败")
+		}
+		area := models.AreaCompressed
+		if source.name == "uploads" {
+			area = models.AreaUploads
+		}
+		h.janitor.Touch(area, key)
+		return &comparisonImage{Name: filename, Source: source.name, data: data}, http.StatusOK, nil
+	}
+	return nil, http.StatusNotFound, fmt.Errorf("文件不存在: %s", filename)
+}
+
+// hashComparisonImage 加载图片并计算感知哈希
+func (h *ImageHandler) hashComparisonImage(c *gin.Context, fileField, nameField string) (*comparisonImage, *models.ImageHashes, int, error) {
+	image, status, err := h.loadComparisonImage(c, fileField, nameField)
+	if err != nil {
+		return nil, nil, status, err
+	}
+	hashes, err := h.imageService.HashImage(image.data)
+	if err != nil {
+		return nil, nil, http.StatusUnprocessableEntity, fmt.Errorf("%s: %v", image.Name, err)
+	}
+	return image, hashes, http.StatusOK, nil
+}
+
+// postFormInt 读取整数表单字段，未提供时返回默认值
+func postFormInt(c *gin.Context, field string, defaultValue int) (int, error) {
+	value := strings.TrimSpace(c.PostForm(field))
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("无效的 %s: %s", field, value)
+	}
+	return n, nil
+}
+
+// FindSimilarImages 查找与指定图片相似的已压缩图片与原始上传文件
+// 图片通过 image 上传或 filename 指定；threshold 为最大汉明距离，algorithm 为 ahash/dhash/phash，limit 为最多返回数量
+// 只在请求所有者（X-Owner 请求头）的图片中查找，未提供时只查找同样未提供所有者的图片
+func (h *ImageHandler) FindSimilarImages(c *gin.Context) {
+	if err := c.Request.ParseMultipartForm(h.maxFileSize); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{
+			Error: "文件太大或请求格式错误",
+		})
+		return
+	}
+
+	// 参数在读取与计算图片哈希之前校验
+	algorithm, err := models.ParseHashAlgorithm(c.PostForm("algorithm"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{
+			Error: err.Error(),
+		})
+		return
+	}
+	threshold, err := postFormInt(c, "threshold", defaultSimilarThreshold)
+	if err == nil {
+		err = models.ValidateHashDistance(threshold)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{
+			Error: err.Error(),
+		})
+		return
+	}
+	limit, err := postFormInt(c, "limit", defaultSimilarLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{
+			Error: err.Error(),
+		})
+		return
+	}
+	meta, err := h.parseRequestMeta(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{
+			Error: err.Error(),
+		})
+		return
+	}
+	query := models.SimilarQuery{
+		Algorithm:   algorithm,
+		MaxDistance: threshold,
+		Owner:       meta.owner,
+		Limit:       limit,
+	}
+
+	image, hashes, status, err := h.hashComparisonImage(c, "image", "filename")
+	if err != nil {
+		c.JSON(status, utils.ResponseError{
+			Error: err.Error(),
+		})
+		return
+	}
+	query.Hashes = *hashes
+	if image.Source != "upload" {
+		query.Exclude = image.Name
+	}
+
+	matches, err := h.catalog.FindSimilar(query)
+	if err != nil {
+		c.JSON(compressErrorStatus(err), utils.ResponseError{
+			Error: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, utils.ResponseSuccess{
+		Message: fmt.Sprintf("找到 %d 张相似图片", len(matches)),
+		Data: gin.H{
+			"image":     image,
+			"hashes":    hashes,
+			"algorithm": algorithm,
+			"threshold": query.MaxDistance,
+			"matches":   matches,
+			"count":     len(matches),
+		},
+	})
+}
+
+// CompareImages 比较两张图片的感知哈希
+// 图片分别通过 image1/image2 上传或 filename1/filename2 指定，threshold 与 algorithm 用于判断是否相似
+func (h *ImageHandler) CompareImages(c *gin.Context) {
+	if err := c.Request.ParseMultipartForm(2 * h.maxFileSize); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{
+			Error: "文件太大或请求格式错误",
+		})
+		return
+	}
+
+	algorithm, err := models.ParseHashAlgorithm(c.PostForm("algorithm"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{
+			Error: err.Error(),
+		})
+		return
+	}
+	threshold, err := postFormInt(c, "threshold", defaultSimilarThreshold)
+	if err == nil {
+		err = models.ValidateHashDistance(threshold)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	first, firstHashes, status, err := h.hashComparisonImage(c, "image1", "filename1")
+	if err != nil {
+		c.JSON(status, utils.ResponseError{
+			Error: "第一张图片: " + err.Error(),
+		})
+		return
+	}
+	second, secondHashes, status, err := h.hashComparisonImage(c, "image2", "filename2")
+	if err != nil {
+		c.JSON(status, utils.ResponseError{
+			Error: "第二张图片: " + err.Error(),
+		})
+		return
+	}
+
+	distances := firstHashes.Distances(*secondHashes)
+	distance := distances.Get(algorithm)
+	c.JSON(http.StatusOK, utils.ResponseSuccess{
+		Message: "比较完成",
+		Data: gin.H{
+			"images":     []*comparisonImage{first, second},
+			"hashes":     []*models.ImageHashes{firstHashes, secondHashes},
+			"distances":  distances,
+			"algorithm":  algorithm,
+			"threshold":  threshold,
+			"distance":   distance,
+			"similarity": models.HashSimilarity(distance),
+			"similar":    distance <= threshold,
+		},
+	})
+}
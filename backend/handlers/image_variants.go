@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mini-toolbox/models"
+	"mini-toolbox/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GenerateVariants 上传图片并生成响应式图片的多个尺寸
+// widths 为逗号分隔的宽度列表，formats 为逗号分隔的输出格式，sizes 与 alt 用于生成 HTML 片段
+func (h *ImageHandler) GenerateVariants(c *gin.Context) {
+	if err := c.Request.ParseMultipartForm(h.maxFileSize); err != nil {
+		c.JSON(http.StatusBadRequest, utils.LegacyErrorResponse{
+			Success: false,
+			Message: "文件太大或请求格式错误",
+		})
+		return
+	}
+
+	file, fileHeader, err := c.Request.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.LegacyErrorResponse{
+			Success: false,
+			Message: "请选择要上传的图片文件",
+		})
+		return
+	}
+	defer file.Close()
+
+	// 根据文件内容校验格式
+	contentType := fileHeader.Header.Get("Content-Type")
+	data, err := h.readUpload(fileHeader.Filename, contentType, file)
+	if err != nil {
+		c.JSON(uploadErrorStatus(err), utils.LegacyErrorResponse{
+			Success: false,
+			Message: err.Error(),
+			Code:    errorCode(err),
+		})
+		return
+	}
+
+	// 获取尺寸、格式与共享的压缩选项
+	spec, err := h.parseVariantSpec(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.LegacyErrorResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// 获取所有者与过期时间
+	meta, err := h.parseRequestMeta(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.LegacyErrorResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	upload, err := h.saveUpload(c.Request.Context(), meta, fileHeader.Filename, contentType, data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.LegacyErrorResponse{
+			Success: false,
+			Message: "保存文件失败",
+		})
+		return
+	}
+
+	set, err := h.imageService.GenerateVariants(c.Request.Context(), upload.Key, spec)
+	if err != nil {
+		h.discardUpload(upload)
+		c.JSON(compressErrorStatus(err), utils.LegacyErrorResponse{
+			Success: false,
+			Message: fmt.Sprintf("生成响应式图片失败: %v", err),
+			Code:    errorCode(err),
+		})
+		return
+	}
+	for _, variant := range set.Variants {
+		h.recordResult(meta, upload.Path, upload.Name, variant.Result, variant.Options)
+	}
+
+	c.JSON(http.StatusOK, utils.LegacySuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("已生成 %d 个尺寸", len(set.Variants)),
+		Data: gin.H{
+			"fileName": upload.Name,
+			"uploadId": upload.Path,
+			"html":     set.HTML(c.PostForm("alt"), c.PostForm("sizes")),
+			"manifest": set,
+		},
+	})
+}
+
+// parseVariantSpec 解析响应式图片的宽度、格式与共享的压缩选项
+func (h *ImageHandler) parseVariantSpec(c *gin.Context) (models.VariantSpec, error) {
+	var spec models.VariantSpec
+	for _, part := range splitList(c.PostForm("widths")) {
+		width, err := strconv.Atoi(part)
+		if err != nil {
+			return spec, fmt.Errorf("无效的宽度: %s", part)
+		}
+		spec.Widths = append(spec.Widths, width)
+	}
+	spec.Formats = splitList(c.PostForm("formats"))
+
+	options, err := h.parseCompressionOptions(c)
+	if err != nil {
+		return spec, err
+	}
+	spec.Options = options
+
+	if err := models.ValidateVariantSpec(&spec); err != nil {
+		return spec, err
+	}
+	return spec, nil
+}
+
+// splitList 拆分逗号分隔的列表，忽略空项
+func splitList(value string) []string {
+	var items []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			items = append(items, part)
+		}
+	}
+	return items
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"mini-toolbox/models"
+	"mini-toolbox/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultWatermarkMargin 未指定边距时水印与边缘的距离（像素）
+const defaultWatermarkMargin = 10
+
+// parseWatermark 解析水印参数
+// 使用 watermarkText 文字或 watermarkImage 上传的 PNG 图片，两者都未提供时返回 nil
+func (h *ImageHandler) parseWatermark(c *gin.Context) (*models.WatermarkOption, error) {
+	watermark := &models.WatermarkOption{
+		Text:    c.PostForm("watermarkText"),
+		Color:   c.PostForm("watermarkColor"),
+		Gravity: c.PostForm("watermarkGravity"),
+		Margin:  defaultWatermarkMargin,
+	}
+
+	// 水印图片先读取并检查格式，其余参数解析完成后再保存到上传存储，选项中只记录文件名
+	var markName, markType string
+	var markData []byte
+	if file, fileHeader, err := c.Request.FormFile("watermarkImage"); err == nil {
+		defer file.Close()
+		markName = utils.SanitizeFilename(fileHeader.Filename)
+		markType = fileHeader.Header.Get("Content-Type")
+		data, err := h.readUpload(markName, markType, file)
+		if err != nil {
+			return nil, fmt.Errorf("水印图片: %v", err)
+		}
+		if format, _ := models.DetectFormat(data); format != "png" {
+			return nil, fmt.Errorf("水印图片必须是 PNG 格式")
+		}
+		markData = data
+	}
+	if watermark.Text == "" && markData == nil {
+		return nil, nil
+	}
+
+	if marginStr := c.PostForm("watermarkMargin"); marginStr != "" {
+		margin, err := strconv.Atoi(marginStr)
+		if err != nil {
+			return nil, fmt.Errorf("无效的水印边距: %s", marginStr)
+		}
+		watermark.Margin = margin
+	}
+	if opacityStr := c.PostForm("watermarkOpacity"); opacityStr != "" {
+		opacity, err := strconv.ParseFloat(opacityStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("无效的水印不透明度: %s", opacityStr)
+		}
+		// 0 在选项中表示使用默认值，显式传入时拒绝，避免被当作默认的 0.5
+		if opacity <= 0 {
+			return nil, fmt.Errorf("水印不透明度必须大于 0")
+		}
+		watermark.Opacity = opacity
+	}
+	if scaleStr := c.PostForm("watermarkScale"); scaleStr != "" {
+		scale, err := strconv.ParseFloat(scaleStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("无效的水印比例: %s", scaleStr)
+		}
+		watermark.Scale = scale
+	}
+	if tiledStr := c.PostForm("watermarkTiled"); tiledStr != "" {
+		if tiled, err := strconv.ParseBool(strings.TrimSpace(tiledStr)); err == nil {
+			watermark.Tiled = tiled
+		}
+	}
+
+	var upload *models.StoredUpload
+	if markData != nil {
+		var err error
+		upload, err = h.saveUpload(c.Request.Context(), requestMeta{owner: requestOwner(c)}, markName, markType, markData)
+		if err != nil {
+			return nil, fmt.Errorf("保存水印图片失败")
+		}
+		watermark.Image = upload.Key
+	}
+
+	// 校验失败时删除本次保存的水印图片
+	if err := models.ValidateWatermark(watermark); err != nil {
+		if upload != nil {
+			h.discardUpload(upload)
+		}
+		return nil, err
+	}
+	return watermark, nil
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"mini-toolbox/models"
+	"mini-toolbox/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobHandler 异步任务处理器
+type JobHandler struct {
+	jobs *models.JobQueue
+}
+
+// NewJobHandler 创建新的异步任务处理器
+func NewJobHandler(jobs *models.JobQueue) *JobHandler {
+	return &JobHandler{
+		jobs: jobs,
+	}
+}
+
+// ListJobs 列出任务，可通过 batch 参数筛选批次
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	jobs := h.jobs.List(c.Query("batch"))
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":    jobs,
+		"count":   len(jobs),
+		"workers": h.jobs.Workers(),
+	})
+}
+
+// GetJob 获取任务状态
+func (h *JobHandler) GetJob(c *gin.Context) {
+	job, err := h.jobs.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, utils.ResponseError{
+			Error: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelJob 取消任务
+func (h *JobHandler) CancelJob(c *gin.Context) {
+	job, err := h.jobs.Cancel(c.Param("id"))
+	switch {
+	case errors.Is(err, models.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, utils.ResponseError{
+			Error: err.Error(),
+		})
+	case errors.Is(err, models.ErrJobFinished):
+		c.JSON(http.StatusConflict, utils.ResponseError{
+			Error: err.Error(),
+		})
+	case job.State == models.JobCancelled:
+		c.JSON(http.StatusOK, utils.ResponseSuccess{
+			Message: "任务已取消",
+			Data:    job,
+		})
+	default:
+		c.JSON(http.StatusAccepted, utils.ResponseSuccess{
+			Message: "已请求取消，任务将在当前处理阶段结束后停止",
+			Data:    job,
+		})
+	}
+}
+
+// sseHeartbeat 进度流的心跳间隔，同时用于补发可能因缓冲区满而丢失的结束事件
+const sseHeartbeat = 15 * time.Second
+
+// StreamJobEvents 以 Server-Sent Events 推送任务或批次的进度
+// 事件名为处理阶段（upload-received/decoding/resizing/encoding/done/failed/cancelled），
+// 所有任务结束后发送 complete 事件并关闭连接
+func (h *JobHandler) StreamJobEvents(c *gin.Context) {
+	id := c.Param("id")
+	events, unsubscribe, err := h.jobs.Subscribe(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, utils.ResponseError{
+			Error: err.Error(),
+		})
+		return
+	}
+	defer unsubscribe()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 关闭 nginx 缓冲
+
+	// 先发送当前状态
+	finished := make(map[string]bool)
+	snapshot := h.jobs.Snapshot(id)
+	for _, event := range snapshot {
+		c.SSEvent(event.Stage, event)
+		finished[event.JobID] = event.Finished()
+	}
+
+	ticker := time.NewTicker(sseHeartbeat)
+	defer ticker.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		if allFinished(finished) {
+			c.SSEvent("complete", h.summary(id))
+			return false
+		}
+
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Stage, event)
+			finished[event.JobID] = event.Finished()
+		case <-ticker.C:
+			// 补发缓冲区满时丢失的结束事件
+			for _, event := range h.jobs.Snapshot(id) {
+				if event.Finished() && !finished[event.JobID] {
+					c.SSEvent(event.Stage, event)
+					finished[event.JobID] = true
+				}
+			}
+			c.SSEvent("ping", gin.H{"time": time.Now()})
+		case <-c.Request.Context().Done():
+			return false
+		}
+		return true
+	})
+}
+
+// summary 统计任务或批次中各状态的任务数量
+func (h *JobHandler) summary(id string) gin.H {
+	counts := make(map[models.JobState]int)
+	snapshot := h.jobs.Snapshot(id)
+	for _, event := range snapshot {
+		counts[event.State]++
+	}
+	return gin.H{
+		"id":     id,
+		"total":  len(snapshot),
+		"states": counts,
+	}
+}
+
+// allFinished 是否所有任务都已结束
+func allFinished(finished map[string]bool) bool {
+	if len(finished) == 0 {
+		return false
+	}
+	for _, done := range finished {
+		if !done {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"mini-toolbox/models"
+	"mini-toolbox/storage"
+	"mini-toolbox/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// transformCacheControl 按需处理结果的缓存头，URL 由上传 ID 与参数决定，内容不会变化
+const transformCacheControl = "public, max-age=31536000, immutable"
+
+// TransformHandler 按 URL 参数按需处理已上传的图片
+type TransformHandler struct {
+	images  models.ImageService // 输出写入处理缓存的图片服务
+	uploads storage.Storage     // 原始上传文件存储
+	cache   storage.Storage     // 处理结果缓存
+	policy  models.URLTransformPolicy
+	catalog models.ImageCatalog // 上传 ID 到上传文件的记录
+	janitor *models.Janitor
+	slots   chan struct{} // 限制同时处理的请求数
+
+	variantsMu sync.Mutex
+	variants   map[string]map[string]bool // 图片 ID -> 已使用的非预设参数组合
+}
+
+// NewTransformHandler 创建按需处理图片的处理器
+func NewTransformHandler(uploads, cache storage.Storage, policy models.URLTransformPolicy, catalog models.ImageCatalog, janitor *models.Janitor, concurrency int) *TransformHandler {
+	return &TransformHandler{
+		images:  models.NewDefaultImageService(uploads, cache),
+		uploads: uploads,
+		cache:   cache,
+		policy:  policy,
+		catalog: catalog,
+		janitor: janitor,
+		slots:   make(chan struct{}, max(concurrency, 1)),
+
+		variants: make(map[string]map[string]bool),
+	}
+}
+
+// Serve 返回按参数处理后的图片，如 /img/w_400,h_300,q_70,fit_fill/<id>.jpg
+// id 为上传接口返回的 id，扩展名决定输出格式
+func (h *TransformHandler) Serve(c *gin.Context) {
+	file, err := resolveFilename(c.Param("file"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{Error: err.Error()})
+		return
+	}
+	ext := filepath.Ext(file)
+	id := strings.TrimSuffix(file, ext)
+	if !isUploadID(id) {
+		c.JSON(http.StatusNotFound, utils.ResponseError{Error: "图片不存在"})
+		return
+	}
+	format, err := models.NormalizeOutputFormat(ext)
+	if err != nil || format == "" {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{Error: fmt.Sprintf("不支持的输出格式: %s", ext)})
+		return
+	}
+
+	options, err := h.policy.Parse(c.Param("params"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ResponseError{Error: err.Error()})
+		return
+	}
+	options.OutputFormat = format
+
+	ctx := c.Request.Context()
+	upload, err := h.catalog.Upload(id)
+	if err != nil {
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusNotFound, utils.ResponseError{Error: "图片不存在"})
+		return
+	}
+	inputKey := upload.Key
+	source, err := h.uploads.Stat(ctx, inputKey)
+	if err != nil {
+		// 上传文件可能已被清理
+		h.forgetVariants(id)
+		c.Header("Cache-Control", "no-store")
+		c.JSON(storageErrorStatus(err), utils.ResponseError{Error: "图片不存在"})
+		return
+	}
+
+	// 原始文件存在且参数相同时内容不变，客户端已有缓存时无需处理
+	variant := models.ResultCacheKey(id, options)[:32]
+	etag := `"` + variant + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", transformCacheControl)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	// 限制每张图片的非预设参数组合数，避免逐个变换参数反复处理并占满缓存
+	if !h.policy.IsPreset(c.Param("params")) && !h.useVariant(id, variant) {
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusTooManyRequests, utils.ResponseError{
+			Error: fmt.Sprintf("该图片的参数组合已超过 %d 种，请使用预设", h.policy.VariantLimit()),
+		})
+		return
+	}
+
+	// 等待处理名额，客户端断开时放弃
+	select {
+	case h.slots <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+	result, err := h.images.CompressCached(ctx, inputKey, options)
+	<-h.slots
+	if err != nil {
+		c.Header("Cache-Control", "no-store")
+		c.JSON(compressErrorStatus(err), utils.ResponseError{Error: fmt.Sprintf("图片处理失败: %v", err)})
+		return
+	}
+	h.janitor.Touch(models.AreaCache, result.Filename)
+	h.janitor.Touch(models.AreaUploads, inputKey)
+
+	data, err := storage.ReadAll(ctx, h.cache, result.Filename)
+	if err != nil {
+		c.Header("Cache-Control", "no-store")
+		c.JSON(storageErrorStatus(err), utils.ResponseError{Error: "读取处理结果失败"})
+		return
+	}
+	c.Header("Content-Type", "image/"+format)
+	http.ServeContent(c.Writer, c.Request, file, source.ModTime, bytes.NewReader(data))
+}
+
+// useVariant 记录图片使用的参数组合，已使用过的组合直接允许，新组合超过上限时返回 false
+func (h *TransformHandler) useVariant(id, variant string) bool {
+	h.variantsMu.Lock()
+	defer h.variantsMu.Unlock()
+	used := h.variants[id]
+	if used[variant] {
+		return true
+	}
+	if len(used) >= h.policy.VariantLimit() {
+		return false
+	}
+	if used == nil {
+		used = make(map[string]bool)
+		h.variants[id] = used
+	}
+	used[variant] = true
+	return true
+}
+
+// forgetVariants 上传文件被清理后移除其参数组合记录
+func (h *TransformHandler) forgetVariants(id string) {
+	h.variantsMu.Lock()
+	delete(h.variants, id)
+	h.variantsMu.Unlock()
+}
+
+// isUploadID 检查是否为上传时生成的随机 ID（32 位小写十六进制）
+func isUploadID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil && strings.ToLower(id) == id
+}
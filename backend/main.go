@@ -1,27 +1,130 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
 
 	"mini-toolbox/models"
 	"mini-toolbox/routes"
+	"mini-toolbox/storage"
+	"mini-toolbox/utils"
 )
 
+// newStorage 根据 STORAGE_BACKEND 创建文件存储
+// local 使用本地目录，s3 使用 S3 兼容服务并以目录名作为对象键前缀
+func newStorage(dir, urlPrefix string) (storage.Storage, error) {
+	switch backend := utils.GetEnv("STORAGE_BACKEND", "local"); backend {
+	case "local":
+		return storage.NewLocalStorage(dir, urlPrefix)
+	case "s3":
+		return storage.NewS3Storage(storage.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			Prefix:    dir + "/",
+			PathStyle: utils.GetEnvBool("S3_PATH_STYLE", true),
+			URLPrefix: urlPrefix,
+		})
+	default:
+		return nil, errors.New("未知的存储类型: " + backend)
+	}
+}
+
 func main() {
-	// 创建必要的目录
-	uploadDir := "uploads"
-	compressedDir := "compressed"
+	// 创建文件存储，访问地址统一经由后端转发，与存储类型无关
+	uploads, err := newStorage("uploads", "/api/uploads")
+	if err != nil {
+		log.Fatal("创建上传文件存储失败:", err)
+	}
+	compressed, err := newStorage("compressed", "/api/static")
+	if err != nil {
+		log.Fatal("创建压缩文件存储失败:", err)
+	}
+	transformCache, err := newStorage("cache", "/img")
+	if err != nil {
+		log.Fatal("创建处理缓存存储失败:", err)
+	}
+
+	// 按需处理的参数限制
+	presets, err := models.ParseURLPresets(os.Getenv("IMG_PRESETS"))
+	if err != nil {
+		log.Fatal("解析图片预设失败:", err)
+	}
+	transformPolicy := models.URLTransformPolicy{
+		MaxDimension:  utils.GetEnvInt("IMG_MAX_DIMENSION", 4096),
+		MaxFilterCost: utils.GetEnvInt("IMG_MAX_FILTER_COST", 20),
+		MaxVariants:   utils.GetEnvInt("IMG_MAX_VARIANTS", 20),
+		Presets:       presets,
+		PresetsOnly:   utils.GetEnvBool("IMG_PRESETS_ONLY", false),
+	}
+	if err := transformPolicy.Validate(); err != nil {
+		log.Fatal("图片预设无效:", err)
+	}
 
-	// 确保目录存在
-	os.MkdirAll(uploadDir, 0755)
-	os.MkdirAll(compressedDir, 0755)
+	// 加载图片目录，并与存储中已有的压缩结果同步
+	catalog, err := models.NewFileCatalog(utils.GetEnv("CATALOG_PATH", "data/catalog.json"))
+	if err != nil {
+		log.Fatal("加载图片目录失败:", err)
+	}
+	if err := catalog.Reconcile(context.Background(), compressed); err != nil {
+		log.Println("同步图片目录失败:", err)
+	}
+	if err := catalog.ReconcileUploads(context.Background(), uploads); err != nil {
+		log.Println("同步上传文件过期时间失败:", err)
+	}
+	// 在后台补充缺失的感知哈希，不阻塞启动，关闭服务时取消
+	backfillCtx, cancelBackfill := context.WithCancel(context.Background())
+	backfillDone := make(chan struct{})
+	go func() {
+		defer close(backfillDone)
+		err := catalog.BackfillHashes(backfillCtx, compressed, utils.GetEnvInt("HASH_BACKFILL_WORKERS", 2))
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Println("补充感知哈希失败:", err)
+		}
+	}()
+
+	// 创建文件清理任务，默认上传文件保留 1 天，压缩结果保留 7 天
+	janitor := models.NewJanitor(models.RetentionPolicy{
+		UploadTTL:     utils.GetEnvDuration("UPLOAD_TTL", 24*time.Hour),
+		CompressedTTL: utils.GetEnvDuration("COMPRESSED_TTL", 7*24*time.Hour),
+		MaxBytes:      utils.GetEnvByteSize("STORAGE_MAX_BYTES", 0),
+		Interval:      utils.GetEnvDuration("SWEEP_INTERVAL", 10*time.Minute),
+	}, uploads, compressed, catalog)
+	janitor.AddArea(models.AreaCache, transformCache, utils.GetEnvDuration("CACHE_TTL", 24*time.Hour))
+	janitor.Start()
 
 	// 创建用户服务
 	userService := models.NewInMemoryUserService()
 
+	// 创建异步任务队列，默认使用一半的 CPU 核心处理图片，避免影响其他接口
+	workers := utils.GetEnvInt("JOB_WORKERS", max(runtime.NumCPU()/2, 1))
+	queueSize := utils.GetEnvInt("JOB_QUEUE_SIZE", 100)
+	jobQueue := models.NewJobQueue(workers, queueSize)
+	log.Printf("异步任务队列: %d 个工作协程，最多排队 %d 个任务", workers, queueSize)
+
 	// 设置路由
-	r := routes.SetupRoutes(userService)
+	r := routes.SetupRoutes(routes.Services{
+		Users:      userService,
+		Jobs:       jobQueue,
+		Catalog:    catalog,
+		Janitor:    janitor,
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
+
+		TransformCache:       transformCache,
+		TransformPolicy:      transformPolicy,
+		TransformConcurrency: utils.GetEnvInt("IMG_CONCURRENCY", workers),
+		Uploads:              uploads,
+		Compressed:           compressed,
+	})
 
 	// 启动服务器在8080端口（与前端配置保持一致）
 	port := ":8080"
@@ -30,7 +133,36 @@ func main() {
 	log.Printf("访问 http://localhost%s/api/v1/users 查看用户API", port)
 	log.Printf("访问 http://localhost%s/api/v1/images 查看图片API", port)
 
-	if err := r.Run(port); err != nil {
-		log.Fatal("启动服务器失败:", err)
+	server := &http.Server{
+		Addr:    port,
+		Handler: r,
+	}
+	// 关闭时结束任务队列，避免进度订阅连接阻塞关闭流程
+	server.RegisterOnShutdown(jobQueue.Close)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("启动服务器失败:", err)
+		}
+	}()
+
+	// 收到退出信号后优雅关闭
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	log.Println("正在关闭服务器...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println("关闭服务器失败:", err)
+	}
+	jobQueue.Close()
+	cancelBackfill()
+	<-backfillDone
+	janitor.Stop()
+	// 写入图片目录中尚未保存的变更
+	if err := catalog.Close(); err != nil {
+		log.Println("保存图片目录失败:", err)
 	}
+	log.Println("服务器已关闭")
 }
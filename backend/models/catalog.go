@@ -0,0 +1,881 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mini-toolbox/storage"
+)
+
+// ErrRecordNotFound 图片记录不存在
+var ErrRecordNotFound = errors.New("图片记录不存在")
+
+const (
+	defaultPageSize   = 20
+	maxPageSize       = 100
+	catalogFlushDelay = time.Second // 记录变更后延迟写入文件的时间，期间的变更合并为一次写入
+)
+
+// ImageRecord 压缩结果的目录记录
+type ImageRecord struct {
+	Filename       string            `json:"filename"`               // 压缩结果的存储文件名
+	DisplayName    string            `json:"displayName"`            // 下载时使用的显示文件名
+	UploadID       string            `json:"uploadId,omitempty"`     // 原始文件的上传文件名 <id><扩展名>
+	OriginalName   string            `json:"originalName,omitempty"` // 原始文件的显示文件名
+	SourceSHA256   string            `json:"sourceSha256,omitempty"` // 原始文件内容的 SHA-256
+	SHA256         string            `json:"sha256,omitempty"`       // 压缩结果内容的 SHA-256
+	Format         string            `json:"format"`                 // 输出格式
+	Width          int               `json:"width,omitempty"`        // 输出宽度
+	Height         int               `json:"height,omitempty"`       // 输出高度
+	Options        CompressionOption `json:"options"`                // 使用的压缩选项
+	OriginalSize   int64             `json:"originalSize,omitempty"` // 原始文件大小（字节）
+	CompressedSize int64             `json:"compressedSize"`         // 压缩后文件大小（字节）
+	Ratio          float64           `json:"ratio,omitempty"`        // 压缩后大小占原始大小的百分比
+	CreatedAt      time.Time         `json:"createdAt"`              // 创建时间
+	Owner          string            `json:"owner,omitempty"`        // 所有者
+	ExpiresAt      *time.Time        `json:"expiresAt,omitempty"`    // 过期时间，为空时按默认保留时间清理
+	Hashes         *ImageHashes      `json:"hashes,omitempty"`       // 输出图片的感知哈希
+}
+
+// UploadRecord 一次上传的记录，相同内容的上传共用一个按 SHA-256 命名的文件
+type UploadRecord struct {
+	ID        string       `json:"id"`                  // 随机生成的上传 ID
+	Key       string       `json:"key"`                 // 存储中的文件名，为 <SHA-256><扩展名>
+	Name      string       `json:"name"`                // 清理后的显示文件名
+	Owner     string       `json:"owner,omitempty"`     // 所有者
+	CreatedAt time.Time    `json:"createdAt"`           // 上传时间
+	ExpiresAt *time.Time   `json:"expiresAt,omitempty"` // 过期时间，为空时按默认保留时间清理
+	Hashes    *ImageHashes `json:"hashes,omitempty"`    // 原始图片的感知哈希
+}
+
+// Path 返回对外使用的文件名 <id><扩展名>
+func (r UploadRecord) Path() string {
+	return r.ID + filepath.Ext(r.Key)
+}
+
+// expired 判断记录是否已过期
+func (r UploadRecord) expired(now time.Time) bool {
+	return r.ExpiresAt != nil && now.After(*r.ExpiresAt)
+}
+
+// CatalogQuery 目录查询条件
+type CatalogQuery struct {
+	Owner    string // 所有者，只返回该所有者的记录
+	Format   string // 按输出格式筛选
+	Name     string // 按显示文件名或原始文件名模糊匹配
+	UploadID string // 按原始文件筛选
+	Sort     string // 排序字段 (createdAt/size/ratio/name)，默认 createdAt
+	Desc     bool   // 是否倒序
+	Page     int    // 页码，从 1 开始
+	PageSize int    // 每页数量，默认 20，最大 100
+}
+
+// SimilarQuery 相似图片查询条件
+type SimilarQuery struct {
+	Hashes      ImageHashes // 待比较图片的感知哈希
+	Algorithm   string      // 使用的算法 (ahash/dhash/phash)，默认 phash
+	MaxDistance int         // 最大汉明距离
+	Owner       string      // 所有者，只在该所有者的记录中查找
+	Exclude     string      // 排除的压缩结果文件名或上传文件名，通常为待比较图片本身
+	Limit       int         // 最多返回数量，默认 20，最大 100
+}
+
+// SimilarImage 相似图片查询结果，来自压缩结果或原始上传文件
+type SimilarImage struct {
+	Source     string        `json:"source"`           // compressed 或 uploads
+	Record     *ImageRecord  `json:"record,omitempty"` // 压缩结果的记录
+	Upload     *UploadRecord `json:"upload,omitempty"` // 原始上传文件的记录
+	Distance   int           `json:"distance"`         // 汉明距离
+	Similarity float64       `json:"similarity"`       // 相似度 (0-1)
+}
+
+// createdAt 返回结果对应记录的创建时间
+func (s SimilarImage) createdAt() time.Time {
+	if s.Record != nil {
+		return s.Record.CreatedAt
+	}
+	return s.Upload.CreatedAt
+}
+
+// catalogSorters 支持的排序字段
+var catalogSorters = map[string]func(a, b ImageRecord) bool{
+	"createdAt": func(a, b ImageRecord) bool { return a.CreatedAt.Before(b.CreatedAt) },
+	"size":      func(a, b ImageRecord) bool { return a.CompressedSize < b.CompressedSize },
+	"ratio":     func(a, b ImageRecord) bool { return a.Ratio < b.Ratio },
+	"name":      func(a, b ImageRecord) bool { return a.DisplayName < b.DisplayName },
+}
+
+// Normalize 校验并补全查询条件
+func (q *CatalogQuery) Normalize() error {
+	if q.Sort == "" {
+		q.Sort = "createdAt"
+	}
+	if _, ok := catalogSorters[q.Sort]; !ok {
+		return fmt.Errorf("%w: 无效的排序字段 %s", ErrInvalidInput, q.Sort)
+	}
+	if q.Format != "" {
+		format, err := NormalizeOutputFormat(q.Format)
+		if err != nil {
+			return err
+		}
+		q.Format = format
+	}
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+	if q.PageSize <= 0 {
+		q.PageSize = defaultPageSize
+	}
+	if q.PageSize > maxPageSize {
+		q.PageSize = maxPageSize
+	}
+	return nil
+}
+
+// match 判断记录是否满足筛选条件
+func (q *CatalogQuery) match(record ImageRecord) bool {
+	if record.Owner != q.Owner {
+		return false
+	}
+	if q.Format != "" && record.Format != q.Format {
+		return false
+	}
+	if q.UploadID != "" && record.UploadID != q.UploadID {
+		return false
+	}
+	if q.Name != "" {
+		name := strings.ToLower(q.Name)
+		if !strings.Contains(strings.ToLower(record.DisplayName), name) &&
+			!strings.Contains(strings.ToLower(record.OriginalName), name) {
+			return false
+		}
+	}
+	return true
+}
+
+// ImageCatalog 图片目录接口
+// 压缩结果按内容寻址，多个所有者可能共用同一个文件，每个所有者各有一条记录
+type ImageCatalog interface {
+	// Add 添加所有者的记录，该所有者已有同名记录时更新显示文件名并合并过期时间，返回 false
+	Add(record ImageRecord) (ImageRecord, bool, error)
+	// Get 获取所有者的记录
+	Get(owner, filename string) (ImageRecord, error)
+	// Delete 删除所有者的记录，返回仍引用该文件的记录数
+	Delete(owner, filename string) (int, error)
+	// DeleteFile 文件已被删除时移除引用它的全部记录
+	DeleteFile(filename string) error
+	// FileExpiry 返回文件的过期时间，任一记录未指定时为空
+	FileExpiry(filename string) *time.Time
+	List(query CatalogQuery) ([]ImageRecord, int, error)
+	// FindSimilar 按感知哈希的汉明距离查找相似的压缩结果与原始上传文件，结果按距离排序
+	FindSimilar(query SimilarQuery) ([]SimilarImage, error)
+	// Reconcile 与存储中的文件同步：补充缺失的记录，移除文件已不存在的记录
+	Reconcile(ctx context.Context, store storage.Storage) error
+	// BackfillHashes 为缺少感知哈希的记录计算哈希，ctx 取消时保存已计算的部分并返回
+	BackfillHashes(ctx context.Context, store storage.Storage, workers int) error
+
+	// AddUpload 登记一次上传，多次上传相同内容时各有一条记录并引用同一个文件
+	AddUpload(record UploadRecord) error
+	// Upload 根据上传 ID 获取记录，已过期的记录视为不存在
+	Upload(id string) (UploadRecord, error)
+	// UploadHashes 返回上传文件已记录的感知哈希，没有时为空
+	UploadHashes(key string) *ImageHashes
+	// ReleaseUpload 删除上传记录，返回仍引用该文件的记录数
+	ReleaseUpload(id string) (int, error)
+	// DeleteUploadFile 上传文件已被删除时移除引用它的全部记录
+	DeleteUploadFile(key string)
+	// UploadExpiry 合并引用该上传文件的全部记录的过期时间，规则与 MergeExpiry 相同，没有记录时为空
+	UploadExpiry(key string) *time.Time
+	// PruneUploads 移除已过期的上传记录，返回移除的数量
+	PruneUploads(now time.Time) int
+	// ReconcileUploads 与存储中的上传文件同步：移除文件已不存在的记录，为没有记录的文件补充记录
+	ReconcileUploads(ctx context.Context, store storage.Storage) error
+}
+
+// catalogFile 目录文件的内容，旧版文件只包含记录数组
+type catalogFile struct {
+	Records       []ImageRecord        `json:"records"`
+	Uploads       []UploadRecord       `json:"uploads,omitempty"`
+	UploadExpires map[string]time.Time `json:"uploadExpires,omitempty"` // 旧版：上传文件名 -> 指定的过期时间，只在加载时读取
+}
+
+// recordKey 目录记录的键，同一文件的不同所有者各有一条记录
+type recordKey struct {
+	owner    string
+	filename string
+}
+
+// FileCatalog 以 JSON 文件持久化的图片目录
+// 变更先写入内存，延迟 catalogFlushDelay 后合并写入文件，关闭时调用 Close 写入剩余变更
+type FileCatalog struct {
+	mu      sync.RWMutex
+	path    string
+	records map[recordKey]ImageRecord
+	refs    map[string]int          // 压缩结果文件名 -> 引用该文件的记录数
+	uploads map[string]UploadRecord // 上传 ID -> 上传记录
+	blobs   map[string]int          // 上传文件名 -> 引用该文件的上传记录数
+	dirty   bool                    // 有尚未写入文件的变更
+	timer   *time.Timer             // 等待中的延迟写入
+
+	writeMu sync.Mutex // 保证文件按变更顺序写入
+}
+
+// NewFileCatalog 创建图片目录，文件存在时加载已有记录
+func NewFileCatalog(path string) (*FileCatalog, error) {
+	c := &FileCatalog{
+		path:    path,
+		records: make(map[recordKey]ImageRecord),
+		refs:    make(map[string]int),
+		uploads: make(map[string]UploadRecord),
+		blobs:   make(map[string]int),
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file catalogFile
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(data, &file.Records)
+	} else {
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析图片目录失败: %v", err)
+	}
+	for _, record := range file.Records {
+		c.putLocked(record)
+	}
+	for _, upload := range file.Uploads {
+		c.putUploadLocked(upload)
+	}
+	for key, expiresAt := range file.UploadExpires {
+		// 旧版以随机 ID 命名上传文件，沿用文件名作为上传 ID
+		expiresAt := expiresAt
+		c.putUploadLocked(UploadRecord{
+			ID:        strings.TrimSuffix(key, filepath.Ext(key)),
+			Key:       key,
+			Name:      key,
+			ExpiresAt: &expiresAt,
+		})
+		c.dirty = true
+	}
+	return c, nil
+}
+
+// putLocked 写入记录并维护文件引用数，调用方需持有写锁
+func (c *FileCatalog) putLocked(record ImageRecord) {
+	key := recordKey{owner: record.Owner, filename: record.Filename}
+	if _, ok := c.records[key]; !ok {
+		c.refs[record.Filename]++
+	}
+	c.records[key] = record
+}
+
+// removeLocked 删除记录并维护文件引用数，返回仍引用该文件的记录数，调用方需持有写锁
+func (c *FileCatalog) removeLocked(key recordKey) int {
+	if _, ok := c.records[key]; ok {
+		delete(c.records, key)
+		c.refs[key.filename]--
+	}
+	remaining := c.refs[key.filename]
+	if remaining <= 0 {
+		delete(c.refs, key.filename)
+	}
+	return remaining
+}
+
+// putUploadLocked 写入上传记录并维护文件引用数，调用方需持有写锁
+func (c *FileCatalog) putUploadLocked(record UploadRecord) {
+	if existing, ok := c.uploads[record.ID]; ok {
+		c.removeUploadLocked(existing.ID)
+	}
+	c.uploads[record.ID] = record
+	c.blobs[record.Key]++
+}
+
+// removeUploadLocked 删除上传记录并维护文件引用数，返回仍引用该文件的记录数，调用方需持有写锁
+func (c *FileCatalog) removeUploadLocked(id string) int {
+	record, ok := c.uploads[id]
+	if !ok {
+		return 0
+	}
+	delete(c.uploads, id)
+	c.blobs[record.Key]--
+	remaining := c.blobs[record.Key]
+	if remaining <= 0 {
+		delete(c.blobs, record.Key)
+	}
+	return remaining
+}
+
+// markDirtyLocked 标记有未写入的变更，并在没有等待中的写入时安排一次延迟写入，调用方需持有写锁
+func (c *FileCatalog) markDirtyLocked() {
+	c.dirty = true
+	if c.timer == nil {
+		c.timer = time.AfterFunc(catalogFlushDelay, func() {
+			if err := c.Flush(); err != nil {
+				log.Printf("写入图片目录失败: %v", err)
+			}
+		})
+	}
+}
+
+// Flush 立即将全部记录写入文件，先写临时文件再重命名，没有变更时不写入
+func (c *FileCatalog) Flush() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.mu.Lock()
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	file := catalogFile{
+		Records: make([]ImageRecord, 0, len(c.records)),
+		Uploads: make([]UploadRecord, 0, len(c.uploads)),
+	}
+	for _, record := range c.records {
+		file.Records = append(file.Records, record)
+	}
+	for _, upload := range c.uploads {
+		file.Uploads = append(file.Uploads, upload)
+	}
+	c.dirty = false
+	c.mu.Unlock()
+
+	sort.Slice(file.Records, func(i, j int) bool {
+		return file.Records[i].CreatedAt.Before(file.Records[j].CreatedAt)
+	})
+	sort.Slice(file.Uploads, func(i, j int) bool {
+		return file.Uploads[i].CreatedAt.Before(file.Uploads[j].CreatedAt)
+	})
+	err := writeFileAtomic(c.path, file)
+	if err != nil {
+		// 写入失败时保留变更，等待下一次写入
+		c.mu.Lock()
+		c.markDirtyLocked()
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// Close 停止延迟写入并写入剩余变更
+func (c *FileCatalog) Close() error {
+	return c.Flush()
+}
+
+// writeFileAtomic 将 value 编码为 JSON 写入临时文件后重命名，避免写入中断时损坏原文件
+func writeFileAtomic(path string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Add 添加所有者的记录
+// 该所有者已有同名记录时保留创建时间与压缩信息，更新为本次的显示文件名，合并过期时间并补充感知哈希，返回 false
+func (c *FileCatalog) Add(record ImageRecord) (ImageRecord, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := recordKey{owner: record.Owner, filename: record.Filename}
+	if existing, ok := c.records[key]; ok {
+		updated := existing
+		updated.DisplayName = record.DisplayName
+		updated.UploadID = record.UploadID
+		updated.OriginalName = record.OriginalName
+		updated.ExpiresAt = MergeExpiry(existing.ExpiresAt, record.ExpiresAt)
+		if updated.Hashes == nil {
+			updated.Hashes = record.Hashes
+		}
+		if updated.DisplayName == existing.DisplayName && updated.UploadID == existing.UploadID &&
+			updated.OriginalName == existing.OriginalName && updated.Hashes == existing.Hashes &&
+			equalExpiry(updated.ExpiresAt, existing.ExpiresAt) {
+			return existing, false, nil
+		}
+		c.records[key] = updated
+		c.markDirtyLocked()
+		return updated, false, nil
+	}
+
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	if record.Format == "" {
+		record.Format, _ = NormalizeOutputFormat(filepath.Ext(record.Filename))
+	}
+	if record.Ratio == 0 && record.OriginalSize > 0 {
+		record.Ratio = float64(record.CompressedSize) / float64(record.OriginalSize) * 100
+	}
+	c.putLocked(record)
+	c.markDirtyLocked()
+	return record, true, nil
+}
+
+// Get 获取所有者的记录
+func (c *FileCatalog) Get(owner, filename string) (ImageRecord, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	record, ok := c.records[recordKey{owner: owner, filename: filename}]
+	if !ok {
+		return ImageRecord{}, ErrRecordNotFound
+	}
+	return record, nil
+}
+
+// Delete 删除所有者的记录，返回仍引用该文件的记录数
+func (c *FileCatalog) Delete(owner, filename string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := recordKey{owner: owner, filename: filename}
+	if _, ok := c.records[key]; !ok {
+		return c.refs[filename], ErrRecordNotFound
+	}
+	remaining := c.removeLocked(key)
+	c.markDirtyLocked()
+	return remaining, nil
+}
+
+// DeleteFile 移除引用该文件的全部记录
+func (c *FileCatalog) DeleteFile(filename string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.refs[filename] == 0 {
+		return ErrRecordNotFound
+	}
+	for key := range c.records {
+		if key.filename == filename {
+			c.removeLocked(key)
+		}
+	}
+	c.markDirtyLocked()
+	return nil
+}
+
+// FileExpiry 合并引用该文件的全部记录的过期时间，规则与 MergeExpiry 相同，没有记录时为空
+func (c *FileCatalog) FileExpiry(filename string) *time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.refs[filename] == 0 {
+		return nil
+	}
+	var expiresAt *time.Time
+	first := true
+	for key, record := range c.records {
+		if key.filename != filename {
+			continue
+		}
+		if first {
+			expiresAt, first = record.ExpiresAt, false
+		} else {
+			expiresAt = MergeExpiry(expiresAt, record.ExpiresAt)
+		}
+	}
+	return expiresAt
+}
+
+// List 按条件筛选、排序并分页，返回当前页记录与满足条件的总数
+func (c *FileCatalog) List(query CatalogQuery) ([]ImageRecord, int, error) {
+	if err := query.Normalize(); err != nil {
+		return nil, 0, err
+	}
+
+	c.mu.RLock()
+	matched := make([]ImageRecord, 0, len(c.records))
+	for _, record := range c.records {
+		if query.match(record) {
+			matched = append(matched, record)
+		}
+	}
+	c.mu.RUnlock()
+
+	less := catalogSorters[query.Sort]
+	sort.SliceStable(matched, func(i, j int) bool {
+		if query.Desc {
+			return less(matched[j], matched[i])
+		}
+		return less(matched[i], matched[j])
+	})
+
+	total := len(matched)
+	start := min((query.Page-1)*query.PageSize, total)
+	end := min(start+query.PageSize, total)
+	return matched[start:end], total, nil
+}
+
+// FindSimilar 按感知哈希的汉明距离查找相似的压缩结果与原始上传文件，没有感知哈希的记录不参与比较
+// 相同内容的多次上传只返回最近一次
+func (c *FileCatalog) FindSimilar(query SimilarQuery) ([]SimilarImage, error) {
+	algorithm, err := ParseHashAlgorithm(query.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateHashDistance(query.MaxDistance); err != nil {
+		return nil, err
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultPageSize
+	}
+	query.Limit = min(query.Limit, maxPageSize)
+
+	target := query.Hashes.Get(algorithm)
+	c.mu.RLock()
+	matched := make([]SimilarImage, 0)
+	for _, record := range c.records {
+		if record.Hashes == nil || record.Filename == query.Exclude || record.Owner != query.Owner {
+			continue
+		}
+		distance := HammingDistance(target, record.Hashes.Get(algorithm))
+		if distance <= query.MaxDistance {
+			record := record
+			matched = append(matched, SimilarImage{
+				Source:     AreaCompressed,
+				Record:     &record,
+				Distance:   distance,
+				Similarity: HashSimilarity(distance),
+			})
+		}
+	}
+	now := time.Now()
+	latest := make(map[string]UploadRecord)
+	for _, upload := range c.uploads {
+		if upload.Hashes == nil || upload.Path() == query.Exclude || upload.Owner != query.Owner || upload.expired(now) {
+			continue
+		}
+		if existing, ok := latest[upload.Key]; !ok || upload.CreatedAt.After(existing.CreatedAt) {
+			latest[upload.Key] = upload
+		}
+	}
+	for _, upload := range latest {
+		distance := HammingDistance(target, upload.Hashes.Get(algorithm))
+		if distance <= query.MaxDistance {
+			upload := upload
+			matched = append(matched, SimilarImage{
+				Source:     AreaUploads,
+				Upload:     &upload,
+				Distance:   distance,
+				Similarity: HashSimilarity(distance),
+			})
+		}
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].Distance != matched[j].Distance {
+			return matched[i].Distance < matched[j].Distance
+		}
+		return matched[i].createdAt().After(matched[j].createdAt())
+	})
+	if len(matched) > query.Limit {
+		matched = matched[:query.Limit]
+	}
+	return matched, nil
+}
+
+// Reconcile 与存储中的文件同步：补充缺失的记录，移除文件已不存在的记录
+func (c *FileCatalog) Reconcile(ctx context.Context, store storage.Storage) error {
+	objects, err := store.List(ctx, "")
+	if err != nil {
+		return err
+	}
+	return c.syncRecords(objects)
+}
+
+// syncRecords 按存储中的文件列表补充与移除记录，没有任何记录的文件补充为无所有者的记录
+func (c *FileCatalog) syncRecords(objects []storage.ObjectInfo) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	present := make(map[string]bool, len(objects))
+	changed := false
+	for _, object := range objects {
+		if _, ok := lookupFormat(object.Key); !ok {
+			continue
+		}
+		present[object.Key] = true
+		if c.refs[object.Key] > 0 {
+			continue
+		}
+		format, _ := NormalizeOutputFormat(filepath.Ext(object.Key))
+		c.putLocked(ImageRecord{
+			Filename:       object.Key,
+			DisplayName:    object.Key,
+			Format:         format,
+			CompressedSize: object.Size,
+			CreatedAt:      object.ModTime,
+		})
+		changed = true
+	}
+	for key := range c.records {
+		if !present[key.filename] {
+			c.removeLocked(key)
+			changed = true
+		}
+	}
+
+	if changed {
+		c.markDirtyLocked()
+	}
+	return nil
+}
+
+// BackfillHashes 为缺少感知哈希的记录读取文件并计算，最多 workers 个文件同时处理，解码时不持有锁
+func (c *FileCatalog) BackfillHashes(ctx context.Context, store storage.Storage, workers int) error {
+	c.mu.RLock()
+	seen := make(map[string]bool)
+	var missing []string
+	for key, record := range c.records {
+		if record.Hashes == nil && !seen[key.filename] {
+			seen[key.filename] = true
+			missing = append(missing, key.filename)
+		}
+	}
+	c.mu.RUnlock()
+	if len(missing) == 0 {
+		return nil
+	}
+
+	filenames := make(chan string)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	computed := make(map[string]*ImageHashes, len(missing))
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for filename := range filenames {
+				data, err := storage.ReadAll(ctx, store, filename)
+				if err != nil {
+					continue
+				}
+				if hashes, err := hashImageData(data); err == nil {
+					mu.Lock()
+					computed[filename] = hashes
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+send:
+	for _, filename := range missing {
+		select {
+		case filenames <- filename:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(filenames)
+	wg.Wait()
+
+	if len(computed) > 0 {
+		c.mu.Lock()
+		for key, record := range c.records {
+			if hashes, ok := computed[key.filename]; ok && record.Hashes == nil {
+				record.Hashes = hashes
+				c.records[key] = record
+			}
+		}
+		c.markDirtyLocked()
+		c.mu.Unlock()
+	}
+	return ctx.Err()
+}
+
+// AddUpload 登记一次上传，未指定上传时间时使用当前时间
+func (c *FileCatalog) AddUpload(record UploadRecord) error {
+	if record.ID == "" || record.Key == "" {
+		return fmt.Errorf("%w: 上传记录缺少 ID 或文件名", ErrInvalidInput)
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.putUploadLocked(record)
+	c.markDirtyLocked()
+	return nil
+}
+
+// UploadHashes 返回上传文件已记录的感知哈希，没有时为空
+func (c *FileCatalog) UploadHashes(key string) *ImageHashes {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.blobs[key] == 0 {
+		return nil
+	}
+	for _, record := range c.uploads {
+		if record.Key == key && record.Hashes != nil {
+			return record.Hashes
+		}
+	}
+	return nil
+}
+
+// Upload 根据上传 ID 获取记录，已过期的记录视为不存在
+func (c *FileCatalog) Upload(id string) (UploadRecord, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	record, ok := c.uploads[id]
+	if !ok || record.expired(time.Now()) {
+		return UploadRecord{}, ErrRecordNotFound
+	}
+	return record, nil
+}
+
+// ReleaseUpload 删除上传记录，返回仍引用该文件的记录数，为 0 时调用方应删除文件
+func (c *FileCatalog) ReleaseUpload(id string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.uploads[id]; !ok {
+		return 0, ErrRecordNotFound
+	}
+	remaining := c.removeUploadLocked(id)
+	c.markDirtyLocked()
+	return remaining, nil
+}
+
+// DeleteUploadFile 移除引用该上传文件的全部记录
+func (c *FileCatalog) DeleteUploadFile(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.blobs[key] == 0 {
+		return
+	}
+	for id, record := range c.uploads {
+		if record.Key == key {
+			c.removeUploadLocked(id)
+		}
+	}
+	c.markDirtyLocked()
+}
+
+// UploadExpiry 合并引用该上传文件的全部记录的过期时间，没有记录时为空
+func (c *FileCatalog) UploadExpiry(key string) *time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.blobs[key] == 0 {
+		return nil
+	}
+	var expiresAt *time.Time
+	first := true
+	for _, record := range c.uploads {
+		if record.Key != key {
+			continue
+		}
+		if first {
+			expiresAt, first = record.ExpiresAt, false
+		} else {
+			expiresAt = MergeExpiry(expiresAt, record.ExpiresAt)
+		}
+	}
+	return expiresAt
+}
+
+// PruneUploads 移除已过期的上传记录，文件仍被其他记录引用时保留文件
+func (c *FileCatalog) PruneUploads(now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for id, record := range c.uploads {
+		if record.expired(now) {
+			c.removeUploadLocked(id)
+			removed++
+		}
+	}
+	if removed > 0 {
+		c.markDirtyLocked()
+	}
+	return removed
+}
+
+// ReconcileUploads 移除存储中已不存在的上传文件的记录，并为没有记录的文件补充以文件名为 ID 的记录，应在开始接收上传前调用
+func (c *FileCatalog) ReconcileUploads(ctx context.Context, store storage.Storage) error {
+	objects, err := store.List(ctx, "")
+	if err != nil {
+		return err
+	}
+	present := make(map[string]bool, len(objects))
+	for _, object := range objects {
+		present[object.Key] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	changed := false
+	for id, record := range c.uploads {
+		if !present[record.Key] {
+			c.removeUploadLocked(id)
+			changed = true
+		}
+	}
+	for _, object := range objects {
+		if c.blobs[object.Key] > 0 {
+			continue
+		}
+		id := strings.TrimSuffix(object.Key, filepath.Ext(object.Key))
+		if _, ok := c.uploads[id]; ok {
+			continue
+		}
+		c.putUploadLocked(UploadRecord{
+			ID:        id,
+			Key:       object.Key,
+			Name:      object.Key,
+			CreatedAt: object.ModTime,
+		})
+		changed = true
+	}
+	if changed {
+		c.markDirtyLocked()
+	}
+	return nil
+}
+
+// MergeExpiry 合并多个请求对同一文件设置的过期时间
+// 任一请求未指定时按默认保留时间处理，否则取较晚的时间，避免缩短其他请求期望的保留时间
+func MergeExpiry(a, b *time.Time) *time.Time {
+	if a == nil || b == nil {
+		return nil
+	}
+	if a.After(*b) {
+		return a
+	}
+	return b
+}
+
+// equalExpiry 判断两个过期时间是否相同
+func equalExpiry(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// CompressedDisplayName 根据原始文件的显示文件名生成压缩结果的显示文件名
+func CompressedDisplayName(originalName, outputFilename string) string {
+	return strings.TrimSuffix(originalName, filepath.Ext(originalName)) + "_compressed" + filepath.Ext(outputFilename)
+}
@@ -0,0 +1,172 @@
+package models
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestCatalog 在临时目录中创建图片目录
+func newTestCatalog(t *testing.T, path string) *FileCatalog {
+	t.Helper()
+	c, err := NewFileCatalog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestUploadReferenceCount(t *testing.T) {
+	c := newTestCatalog(t, filepath.Join(t.TempDir(), "catalog.json"))
+	for _, id := range []string{"a", "b"} {
+		if err := c.AddUpload(UploadRecord{ID: id, Key: "sha.png", Name: id + ".png"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.AddUpload(UploadRecord{ID: "c"}); err == nil {
+		t.Error("AddUpload 缺少文件名时应返回错误")
+	}
+
+	tests := []struct {
+		id        string
+		remaining int
+		err       error
+	}{
+		{"a", 1, nil},
+		{"a", 0, ErrRecordNotFound},
+		{"b", 0, nil},
+	}
+	for _, tt := range tests {
+		remaining, err := c.ReleaseUpload(tt.id)
+		if remaining != tt.remaining || !errors.Is(err, tt.err) {
+			t.Errorf("ReleaseUpload(%q) = %d, %v, want %d, %v", tt.id, remaining, err, tt.remaining, tt.err)
+		}
+	}
+	if _, err := c.Upload("b"); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("Upload(b) 错误 = %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestUploadExpiry(t *testing.T) {
+	now := time.Now()
+	past, soon, later := now.Add(-time.Hour), now.Add(time.Hour), now.Add(2*time.Hour)
+
+	tests := []struct {
+		name    string
+		expires []*time.Time
+		want    *time.Time
+	}{
+		{"单条记录", []*time.Time{&soon}, &soon},
+		{"取较晚的时间", []*time.Time{&soon, &later}, &later},
+		{"有记录未设置时按默认保留时间", []*time.Time{&later, nil}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCatalog(t, filepath.Join(t.TempDir(), "catalog.json"))
+			for i, expiresAt := range tt.expires {
+				c.AddUpload(UploadRecord{ID: string(rune('a' + i)), Key: "sha.png", ExpiresAt: expiresAt})
+			}
+			if got := c.UploadExpiry("sha.png"); !equalExpiry(got, tt.want) {
+				t.Errorf("UploadExpiry = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("过期记录", func(t *testing.T) {
+		c := newTestCatalog(t, filepath.Join(t.TempDir(), "catalog.json"))
+		c.AddUpload(UploadRecord{ID: "old", Key: "sha.png", ExpiresAt: &past})
+		c.AddUpload(UploadRecord{ID: "new", Key: "sha.png", ExpiresAt: &soon})
+		if _, err := c.Upload("old"); !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("Upload(old) 错误 = %v, want ErrRecordNotFound", err)
+		}
+		if removed := c.PruneUploads(now); removed != 1 {
+			t.Errorf("PruneUploads = %d, want 1", removed)
+		}
+		if got := c.UploadExpiry("sha.png"); !equalExpiry(got, &soon) {
+			t.Errorf("UploadExpiry = %v, want %v", got, soon)
+		}
+	})
+}
+
+func TestCatalogPersistUploads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	c, err := NewFileCatalog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.AddUpload(UploadRecord{ID: "a", Key: "sha.png", Name: "cat.png", Owner: "alice"})
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := newTestCatalog(t, path)
+	record, err := reopened.Upload("a")
+	if err != nil || record.Key != "sha.png" || record.Name != "cat.png" || record.Owner != "alice" || record.Path() != "a.png" {
+		t.Errorf("Upload(a) = %+v, %v", record, err)
+	}
+}
+
+func TestCatalogLegacyUploadExpires(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	legacy := `{"records":[],"uploadExpires":{"0123.jpg":"2099-01-01T00:00:00Z"}}`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestCatalog(t, path)
+	record, err := c.Upload("0123")
+	if err != nil || record.Key != "0123.jpg" || record.ExpiresAt == nil || record.ExpiresAt.Year() != 2099 {
+		t.Errorf("Upload(0123) = %+v, %v", record, err)
+	}
+}
+
+func TestFindSimilarOwner(t *testing.T) {
+	c := newTestCatalog(t, filepath.Join(t.TempDir(), "catalog.json"))
+	hashes := &ImageHashes{PHash: 0xff}
+	near := &ImageHashes{PHash: 0xfe}
+	now := time.Now()
+	c.Add(ImageRecord{Filename: "x.jpg", Owner: "alice", Hashes: near, CreatedAt: now})
+	c.Add(ImageRecord{Filename: "y.jpg", Owner: "bob", Hashes: hashes, CreatedAt: now})
+	// 同一文件的两次上传只返回最新的一次
+	c.AddUpload(UploadRecord{ID: "u1", Key: "sha.png", Owner: "alice", Hashes: hashes, CreatedAt: now.Add(-time.Minute)})
+	c.AddUpload(UploadRecord{ID: "u2", Key: "sha.png", Owner: "alice", Hashes: hashes, CreatedAt: now})
+
+	tests := []struct {
+		name    string
+		owner   string
+		exclude string
+		want    []string
+	}{
+		{"所有者的压缩结果与上传", "alice", "", []string{"u2.png", "x.jpg"}},
+		{"排除自身", "alice", "u2.png", []string{"u1.png", "x.jpg"}},
+		{"其他所有者", "bob", "", []string{"y.jpg"}},
+		{"无所有者", "", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, err := c.FindSimilar(SimilarQuery{Hashes: *hashes, Owner: tt.owner, Exclude: tt.exclude, MaxDistance: 4})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range matched {
+				if m.Upload != nil {
+					got = append(got, m.Upload.Path())
+				} else {
+					got = append(got, m.Record.Filename)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("FindSimilar = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("FindSimilar = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
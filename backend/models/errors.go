@@ -6,4 +6,9 @@ import "errors"
 var (
 	ErrUserNotFound = errors.New("用户未找到")
 	ErrInvalidInput = errors.New("无效的输入数据")
+
+	ErrUnsupportedOutputFormat = errors.New("不支持的输出格式")
+	ErrInvalidColor            = errors.New("无效的颜色值")
+	ErrTargetSizeUnreachable   = errors.New("无法将图片压缩到目标大小")
+	ErrQualityBelowThreshold   = errors.New("压缩结果低于最低画质要求")
 )
@@ -0,0 +1,363 @@
+package models
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// EXIF 中使用的标签
+const (
+	tagOrientation     = 0x0112
+	tagArtist          = 0x013B
+	tagCopyright       = 0x8298
+	tagExifIFDPointer  = 0x8769
+	tagGPSInfoPointer  = 0x8825
+	exifTypeASCII      = 2
+	exifTypeShort      = 3
+	maxExifIFDEntries  = 512
+	jpegMarkerAPP1     = 0xE1
+	jpegMarkerSOS      = 0xDA
+	jpegSegmentMaxSize = 0xFFFF
+)
+
+// exifHeader JPEG APP1 段中 EXIF 数据的标识
+var exifHeader = []byte("Exif\x00\x00")
+
+// errNoExif 图片不包含 EXIF 数据
+var errNoExif = errors.New("图片不包含 EXIF 数据")
+
+// ifdTagNames 主 IFD 与 Exif 子 IFD 的标签名称
+var ifdTagNames = map[uint16]string{
+	0x010E: "ImageDescription",
+	0x010F: "Make",
+	0x0110: "Model",
+	0x0112: "Orientation",
+	0x011A: "XResolution",
+	0x011B: "YResolution",
+	0x0128: "ResolutionUnit",
+	0x0131: "Software",
+	0x0132: "DateTime",
+	0x013B: "Artist",
+	0x8298: "Copyright",
+	0x829A: "ExposureTime",
+	0x829D: "FNumber",
+	0x8822: "ExposureProgram",
+	0x8827: "ISOSpeedRatings",
+	0x9003: "DateTimeOriginal",
+	0x9004: "DateTimeDigitized",
+	0x9201: "ShutterSpeedValue",
+	0x9202: "ApertureValue",
+	0x9204: "ExposureBiasValue",
+	0x9207: "MeteringMode",
+	0x9209: "Flash",
+	0x920A: "FocalLength",
+	0xA001: "ColorSpace",
+	0xA002: "PixelXDimension",
+	0xA003: "PixelYDimension",
+	0xA405: "FocalLengthIn35mmFilm",
+	0xA431: "BodySerialNumber",
+	0xA433: "LensMake",
+	0xA434: "LensModel",
+}
+
+// gpsTagNames GPS 子 IFD 的标签名称
+var gpsTagNames = map[uint16]string{
+	0x0000: "GPSVersionID",
+	0x0001: "GPSLatitudeRef",
+	0x0002: "GPSLatitude",
+	0x0003: "GPSLongitudeRef",
+	0x0004: "GPSLongitude",
+	0x0005: "GPSAltitudeRef",
+	0x0006: "GPSAltitude",
+	0x0007: "GPSTimeStamp",
+	0x0010: "GPSImgDirectionRef",
+	0x0011: "GPSImgDirection",
+	0x001D: "GPSDateStamp",
+}
+
+// exifTypeSizes EXIF 数据类型对应的字节数
+var exifTypeSizes = map[uint16]int{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+// ExifData 解析后的 EXIF 数据
+type ExifData struct {
+	Orientation int               // 方向标签，缺失时为 1
+	Tags        map[string]string // 标签名称到可读值的映射
+
+	raw               []byte           // APP1 段中 "Exif\0\0" 之后的 TIFF 数据
+	order             binary.ByteOrder // TIFF 字节序
+	orientationOffset int              // 方向值在 raw 中的偏移，-1 表示不存在
+	asciiTags         map[uint16]string
+}
+
+// HasGPS 是否包含 GPS 定位信息
+func (e *ExifData) HasGPS() bool {
+	for name := range e.Tags {
+		if strings.HasPrefix(name, "GPS") {
+			return true
+		}
+	}
+	return false
+}
+
+// findJPEGExif 在 JPEG 数据中查找 EXIF APP1 段，返回段内 TIFF 数据
+func findJPEGExif(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errNoExif
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, errNoExif
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			// 填充字节
+			pos++
+			continue
+		}
+		if marker == jpegMarkerSOS {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil, errNoExif
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == jpegMarkerAPP1 && bytes.HasPrefix(segment, exifHeader) {
+			return segment[len(exifHeader):], nil
+		}
+		pos += 2 + length
+	}
+	return nil, errNoExif
+}
+
+// ParseJPEGExif 解析 JPEG 数据中的 EXIF 信息
+func ParseJPEGExif(data []byte) (*ExifData, error) {
+	raw, err := findJPEGExif(data)
+	if err != nil {
+		return nil, err
+	}
+	return parseExif(raw)
+}
+
+// parseExif 解析 TIFF 结构的 EXIF 数据
+func parseExif(raw []byte) (*ExifData, error) {
+	if len(raw) < 8 {
+		return nil, errNoExif
+	}
+
+	var order binary.ByteOrder
+	switch string(raw[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("无效的 EXIF 字节序")
+	}
+	if order.Uint16(raw[2:]) != 42 {
+		return nil, fmt.Errorf("无效的 EXIF 头")
+	}
+
+	exif := &ExifData{
+		Orientation:       1,
+		Tags:              make(map[string]string),
+		raw:               raw,
+		order:             order,
+		orientationOffset: -1,
+		asciiTags:         make(map[uint16]string),
+	}
+
+	ifd0 := int(order.Uint32(raw[4:]))
+	pointers, err := exif.readIFD(ifd0, ifdTagNames)
+	if err != nil {
+		return nil, err
+	}
+	if offset, ok := pointers[tagExifIFDPointer]; ok {
+		if _, err := exif.readIFD(offset, ifdTagNames); err != nil {
+			return nil, err
+		}
+	}
+	if offset, ok := pointers[tagGPSInfoPointer]; ok {
+		if _, err := exif.readIFD(offset, gpsTagNames); err != nil {
+			return nil, err
+		}
+	}
+	return exif, nil
+}
+
+// readIFD 读取一个 IFD 中的标签，返回其中子 IFD 指针
+func (e *ExifData) readIFD(offset int, names map[uint16]string) (map[uint16]int, error) {
+	raw, order := e.raw, e.order
+	if offset < 8 || offset+2 > len(raw) {
+		return nil, fmt.Errorf("无效的 IFD 偏移")
+	}
+	count := int(order.Uint16(raw[offset:]))
+	if count > maxExifIFDEntries || offset+2+count*12 > len(raw) {
+		return nil, fmt.Errorf("无效的 IFD 条目数")
+	}
+
+	pointers := make(map[uint16]int)
+	for i := 0; i < count; i++ {
+		entry := raw[offset+2+i*12 : offset+2+(i+1)*12]
+		tag := order.Uint16(entry[0:])
+		typ := order.Uint16(entry[2:])
+		n := int(order.Uint32(entry[4:]))
+
+		size, ok := exifTypeSizes[typ]
+		if !ok || n < 0 || n > len(raw) {
+			continue
+		}
+		valueOffset := offset + 2 + i*12 + 8
+		if size*n > 4 {
+			valueOffset = int(order.Uint32(entry[8:]))
+		}
+		if valueOffset < 0 || valueOffset+size*n > len(raw) {
+			continue
+		}
+		value := raw[valueOffset : valueOffset+size*n]
+
+		if tag == tagExifIFDPointer || tag == tagGPSInfoPointer {
+			if len(value) < 4 {
+				continue
+			}
+			pointers[tag] = int(order.Uint32(value))
+			continue
+		}
+
+		name, known := names[tag]
+		if !known {
+			name = fmt.Sprintf("Tag0x%04X", tag)
+		}
+		formatted := formatExifValue(order, typ, n, value)
+		e.Tags[name] = formatted
+
+		if known && tag == tagOrientation && typ == exifTypeShort && n >= 1 {
+			e.Orientation = int(order.Uint16(value))
+			e.orientationOffset = valueOffset
+		}
+		if known && (tag == tagArtist || tag == tagCopyright) && typ == exifTypeASCII {
+			e.asciiTags[tag] = formatted
+		}
+	}
+	return pointers, nil
+}
+
+// formatExifValue 将 EXIF 值转换为可读字符串
+func formatExifValue(order binary.ByteOrder, typ uint16, n int, value []byte) string {
+	switch typ {
+	case 2: // ASCII
+		return strings.TrimSpace(strings.TrimRight(string(value), "\x00"))
+	case 7: // UNDEFINED
+		if n <= 16 {
+			return fmt.Sprintf("%x", value)
+		}
+		return fmt.Sprintf("(%d 字节)", n)
+	}
+
+	parts := make([]string, 0, 17)
+	size := exifTypeSizes[typ]
+	for i := 0; i < n && i < 16; i++ {
+		v := value[i*size : (i+1)*size]
+		switch typ {
+		case 1, 6:
+			parts = append(parts, fmt.Sprintf("%d", v[0]))
+		case 3:
+			parts = append(parts, fmt.Sprintf("%d", order.Uint16(v)))
+		case 8:
+			parts = append(parts, fmt.Sprintf("%d", int16(order.Uint16(v))))
+		case 4:
+			parts = append(parts, fmt.Sprintf("%d", order.Uint32(v)))
+		case 9:
+			parts = append(parts, fmt.Sprintf("%d", int32(order.Uint32(v))))
+		case 5:
+			parts = append(parts, fmt.Sprintf("%d/%d", order.Uint32(v), order.Uint32(v[4:])))
+		case 10:
+			parts = append(parts, fmt.Sprintf("%d/%d", int32(order.Uint32(v)), int32(order.Uint32(v[4:]))))
+		default:
+			parts = append(parts, fmt.Sprintf("%x", v))
+		}
+	}
+	if n > 16 {
+		parts = append(parts, "...")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// withNormalOrientation 返回方向标签重置为 1 的 TIFF 数据副本
+func (e *ExifData) withNormalOrientation() []byte {
+	raw := append([]byte(nil), e.raw...)
+	if e.orientationOffset >= 0 {
+		e.order.PutUint16(raw[e.orientationOffset:], 1)
+	}
+	return raw
+}
+
+// copyrightOnly 生成只包含作者与版权标签的 TIFF 数据，没有这些标签时返回 nil
+func (e *ExifData) copyrightOnly() []byte {
+	tags := make([]uint16, 0, 2)
+	for _, tag := range []uint16{tagArtist, tagCopyright} {
+		if _, ok := e.asciiTags[tag]; ok {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	order := binary.LittleEndian
+	header := []byte{'I', 'I', 42, 0, 8, 0, 0, 0}
+	ifdSize := 2 + len(tags)*12 + 4
+	dataOffset := 8 + ifdSize
+
+	ifd := make([]byte, ifdSize)
+	order.PutUint16(ifd, uint16(len(tags)))
+	var values []byte
+	for i, tag := range tags {
+		value := append([]byte(e.asciiTags[tag]), 0)
+		entry := ifd[2+i*12:]
+		order.PutUint16(entry[0:], tag)
+		order.PutUint16(entry[2:], exifTypeASCII)
+		order.PutUint32(entry[4:], uint32(len(value)))
+		if len(value) <= 4 {
+			copy(entry[8:12], value)
+			continue
+		}
+		order.PutUint32(entry[8:], uint32(dataOffset+len(values)))
+		values = append(values, value...)
+		if len(values)%2 == 1 {
+			values = append(values, 0)
+		}
+	}
+
+	out := append(header, ifd...)
+	return append(out, values...)
+}
+
+// exifSegmentSize 返回 TIFF 数据写入 APP1 段后占用的字节数
+func exifSegmentSize(tiff []byte) int {
+	return 4 + len(exifHeader) + len(tiff)
+}
+
+// insertJPEGExif 将 EXIF 数据作为 APP1 段插入到 JPEG 数据的 SOI 之后
+func insertJPEGExif(jpegData, tiff []byte) ([]byte, error) {
+	if len(jpegData) < 2 || jpegData[0] != 0xFF || jpegData[1] != 0xD8 {
+		return nil, fmt.Errorf("无效的 JPEG 数据")
+	}
+	length := 2 + len(exifHeader) + len(tiff)
+	if length > jpegSegmentMaxSize {
+		return nil, fmt.Errorf("EXIF 数据过大")
+	}
+
+	out := make([]byte, 0, len(jpegData)+length+2)
+	out = append(out, 0xFF, 0xD8, 0xFF, jpegMarkerAPP1, byte(length>>8), byte(length))
+	out = append(out, exifHeader...)
+	out = append(out, tiff...)
+	return append(out, jpegData[2:]...), nil
+}
@@ -1,19 +1,17 @@
 package models
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"image"
-	"image/jpeg"
-	"image/png"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
-	"github.com/disintegration/imaging"
-	"github.com/nfnt/resize"
+	"mini-toolbox/storage"
 )
 
 // CompressionOption 压缩选项
@@ -22,50 +20,112 @@ type CompressionOption struct {
 	Width      uint `json:"width"`      // 目标宽度，0 表示保持原比例
 	Height     uint `json:"height"`     // 目标高度，0 表示保持原比例
 	KeepAspect bool `json:"keepAspect"` // 是否保持宽高比
+
+	OutputFormat string `json:"outputFormat"` // 输出格式 (jpeg/png/gif/bmp/tiff)，为空时沿用原格式
+	Background   string `json:"background"`   // 输出格式不支持透明时的铺底颜色，如 #ffffff
+
+	TargetSize     int64 `json:"targetSize"`     // 目标文件大小（字节），0 表示不限制
+	AllowDownscale bool  `json:"allowDownscale"` // 目标大小模式下最低质量仍超出时是否允许缩小尺寸
+
+	PNGCompression string `json:"pngCompression"` // PNG 压缩级别 (default/none/speed/best)
+	Colors         int    `json:"colors"`         // PNG/GIF 调色板颜色数量 (2-256)，0 表示不量化
+	Dither         bool   `json:"dither"`         // 量化时是否使用 Floyd-Steinberg 抖动
+
+	Metadata string `json:"metadata"` // 元数据处理模式 (strip/keep/keep-copyright)，默认移除
+
+	Transforms []TransformOp `json:"transforms,omitempty"` // 调整尺寸前按顺序执行的变换操作
+
+	Mode          string  `json:"mode"`          // 缩放模式 (fit/fill/stretch/percentage/max-megapixels)，为空时由 KeepAspect 决定
+	Anchor        string  `json:"anchor"`        // fill 模式裁剪时的锚点，默认 center
+	Percentage    float64 `json:"percentage"`    // percentage 模式的缩放百分比
+	MaxMegapixels float64 `json:"maxMegapixels"` // max-megapixels 模式的最大像素数（百万）
+	Filter        string  `json:"filter"`        // 重采样滤镜 (lanczos/catmullrom/linear/nearest)，默认 lanczos
+	NoUpscale     bool    `json:"noUpscale"`     // 是否禁止放大
+
+	Filters   []FilterOp       `json:"filters,omitempty"`   // 调整尺寸后按顺序执行的滤镜与调整
+	Watermark *WatermarkOption `json:"watermark,omitempty"` // 滤镜之后叠加的水印
+
+	MinSSIM float64 `json:"minSSIM,omitempty"` // 最低 SSIM (0-1)，JPEG 输出不满足时提高质量重试，其他情况拒绝，0 表示不限制
 }
 
 // CompressResult 压缩结果
 type CompressResult struct {
-	OriginalSize   int64  `json:"originalSize"`   // 原始文件大小（字节）
-	CompressedSize int64  `json:"compressedSize"` // 压缩后文件大小（字节）
-	Filename       string `json:"filename"`       // 压缩后文件名
-	CompressionURL string `json:"compressionUrl"` // 压缩后文件访问URL
-	Ratio          string `json:"ratio"`          // 压缩比例
+	OriginalSize       int64  `json:"originalSize"`                 // 原始文件大小（字节）
+	CompressedSize     int64  `json:"compressedSize"`               // 压缩后文件大小（字节）
+	Filename           string `json:"filename"`                     // 压缩后文件名
+	CompressionURL     string `json:"compressionUrl"`               // 压缩后文件访问URL
+	Ratio              string `json:"ratio"`                        // 压缩比例
+	Quality            int    `json:"quality,omitempty"`            // 实际使用的 JPEG 质量
+	Iterations         int    `json:"iterations,omitempty"`         // 目标大小模式下的编码次数
+	KeptOriginal       bool   `json:"keptOriginal,omitempty"`       // 重新编码后更大，保留了原始文件
+	LargerThanOriginal bool   `json:"largerThanOriginal,omitempty"` // 结果比原始文件大，如显式指定了输出格式或调整了尺寸
+	Cached             bool   `json:"cached"`                       // 命中缓存，直接返回了已有结果
+	DisplayName        string `json:"displayName,omitempty"`        // 下载时使用的显示文件名
+	Width              int    `json:"width,omitempty"`              // 输出宽度
+	Height             int    `json:"height,omitempty"`             // 输出高度
+	SourceSHA256       string `json:"sourceSha256,omitempty"`       // 原始文件内容的 SHA-256
+	SHA256             string `json:"sha256,omitempty"`             // 压缩结果内容的 SHA-256
+
+	Hashes  *ImageHashes    `json:"hashes,omitempty"`  // 输出图片的感知哈希
+	Metrics *QualityMetrics `json:"metrics,omitempty"` // 相对编码前图片的画质指标，命中重启前生成的结果时为空
 }
 
 // ImageService 图片服务接口
 type ImageService interface {
-	CompressImage(inputPath, outputPath string, options CompressionOption) (*CompressResult, error)
+	CompressImage(inputKey, outputKey string, options CompressionOption) (*CompressResult, error)
+	CompressImageContext(ctx context.Context, inputKey, outputKey string, options CompressionOption) (*CompressResult, error)
+	CompressCached(ctx context.Context, inputKey string, options CompressionOption) (*CompressResult, error)
+	GenerateVariants(ctx context.Context, inputKey string, spec VariantSpec) (*VariantSet, error)
+	HashImage(data []byte) (*ImageHashes, error)
+	DiffImages(first, second []byte, threshold int) (*ImageDiff, error)
+	ExtractPalette(data []byte, size int) (*ColorPalette, error)
+	SaveUpload(ctx context.Context, filename, contentType string, data []byte) (*StoredUpload, error)
 	GetSupportedFormats() []string
+	GetFormatDetails() []FormatInfo
 	ValidateImageFormat(filename string) bool
+	ValidateUpload(filename, contentType string, data []byte) (string, error)
+	Inspect(r io.Reader) (*ImageInfo, error)
 }
 
 // DefaultImageService 默认图片服务实现
 type DefaultImageService struct {
-	supportedFormats []string
-	uploadDir        string
-	compressedDir    string
+	supportedFormats []FormatInfo
+	uploads          storage.Storage // 原始上传文件存储
+	compressed       storage.Storage // 压缩结果存储
+	cache            *resultCache    // 压缩结果缓存
+	sources          *sourceIndex    // 上传文件的内容哈希索引
 }
 
 // NewDefaultImageService 创建默认图片服务
-func NewDefaultImageService(uploadDir, compressedDir string) *DefaultImageService {
+func NewDefaultImageService(uploads, compressed storage.Storage) *DefaultImageService {
 	return &DefaultImageService{
-		supportedFormats: []string{".jpg", ".jpeg", ".png"},
-		uploadDir:        uploadDir,
-		compressedDir:    compressedDir,
+		supportedFormats: formatTable,
+		uploads:          uploads,
+		compressed:       compressed,
+		cache:            newResultCache(),
+		sources:          newSourceIndex(),
 	}
 }
 
 // GetSupportedFormats 获取支持的图片格式
 func (s *DefaultImageService) GetSupportedFormats() []string {
+	formats := make([]string, 0, len(s.supportedFormats))
+	for _, info := range s.supportedFormats {
+		formats = append(formats, info.Extension)
+	}
+	return formats
+}
+
+// GetFormatDetails 获取各格式的解码与输出格式说明
+func (s *DefaultImageService) GetFormatDetails() []FormatInfo {
 	return s.supportedFormats
 }
 
 // ValidateImageFormat 验证图片格式
 func (s *DefaultImageService) ValidateImageFormat(filename string) bool {
 	ext := strings.ToLower(filepath.Ext(filename))
-	for _, format := range s.supportedFormats {
-		if ext == format {
+	for _, info := range s.supportedFormats {
+		if ext == info.Extension {
 			return true
 		}
 	}
@@ -73,98 +133,235 @@ func (s *DefaultImageService) ValidateImageFormat(filename string) bool {
 }
 
 // CompressImage 压缩图片
-func (s *DefaultImageService) CompressImage(inputPath, outputPath string, options CompressionOption) (*CompressResult, error) {
-	// 获取原始文件信息
-	originalInfo, err := os.Stat(inputPath)
+// inputKey 为上传存储中的文件名，outputKey 为压缩结果存储中的文件名
+func (s *DefaultImageService) CompressImage(inputKey, outputKey string, options CompressionOption) (*CompressResult, error) {
+	return s.CompressImageContext(context.Background(), inputKey, outputKey, options)
+}
+
+// CompressImageContext 压缩图片，在各处理阶段之间检查 ctx 是否已取消
+func (s *DefaultImageService) CompressImageContext(ctx context.Context, inputKey, outputKey string, options CompressionOption) (*CompressResult, error) {
+	source, err := s.loadSource(ctx, inputKey)
 	if err != nil {
-		return nil, fmt.Errorf("无法获取原始文件信息: %v", err)
+		return nil, err
 	}
+	return s.compressSource(ctx, source, outputKey, options)
+}
+
+// sourceImage 解码后的原始图片，同一原始图片生成多个输出时只需解码一次
+type sourceImage struct {
+	data   []byte      // 原始文件内容
+	img    image.Image // 按 EXIF 方向校正后的图片
+	format string      // 解码格式
+	exif   *ExifData   // JPEG 的 EXIF 信息，没有时为 nil
+}
 
-	// 打开原始图片
-	inputFile, err := os.Open(inputPath)
+// loadSource 读取并解码原始图片，按 EXIF 方向标签校正
+func (s *DefaultImageService) loadSource(ctx context.Context, inputKey string) (*sourceImage, error) {
+	// 读取原始图片
+	reportProgress(ctx, StageDecoding, 10)
+	original, err := storage.ReadAll(ctx, s.uploads, inputKey)
 	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("无法打开输入文件: %v", err)
 	}
-	defer inputFile.Close()
 
-	// 解码图片
-	img, format, err := image.Decode(inputFile)
+	// 解码图片并按 EXIF 方向标签校正
+	img, format, exif, err := decodeOriented(original)
 	if err != nil {
-		return nil, fmt.Errorf("无法解码图片: %v", err)
+		return nil, err
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &sourceImage{data: original, img: img, format: format, exif: exif}, nil
+}
+
+// decodeOriented 解码图片，JPEG 按 EXIF 方向标签校正，没有 EXIF 信息时 exif 为 nil
+func decodeOriented(data []byte) (image.Image, string, *ExifData, error) {
+	img, format, err := decodeImage(data)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	var exif *ExifData
+	if format == "jpeg" {
+		if exif, err = ParseJPEGExif(data); err == nil {
+			img = applyOrientation(img, exif.Orientation)
+		}
+	}
+	return img, format, exif, nil
+}
+
+// compressSource 按压缩选项处理已解码的原始图片并写入压缩结果存储
+func (s *DefaultImageService) compressSource(ctx context.Context, source *sourceImage, outputKey string, options CompressionOption) (*CompressResult, error) {
+	var err error
+	original, img, format, exif := source.data, source.img, source.format, source.exif
+	originalSize := int64(len(original))
+	originalBounds := img.Bounds()
+	if err := ValidateMinSSIM(options.MinSSIM); err != nil {
+		return nil, err
+	}
+
+	// 执行变换操作
+	reportProgress(ctx, StageResizing, 40)
+	if len(options.Transforms) > 0 {
+		if img, err = applyTransforms(img, options.Transforms); err != nil {
+			return nil, err
+		}
+	}
+
+	// 像素内容是否在尺寸变化之外被修改
+	modified := (exif != nil && exif.Orientation > 1) || len(options.Transforms) > 0 || len(options.Filters) > 0 || options.Watermark != nil
 
 	// 调整图片尺寸
-	if options.Width > 0 || options.Height > 0 {
-		if options.KeepAspect {
-			// 保持宽高比，使用 imaging 库
-			if options.Width > 0 && options.Height > 0 {
-				img = imaging.Fit(img, int(options.Width), int(options.Height), imaging.Lanczos)
-			} else if options.Width > 0 {
-				img = imaging.Resize(img, int(options.Width), 0, imaging.Lanczos)
-			} else if options.Height > 0 {
-				img = imaging.Resize(img, 0, int(options.Height), imaging.Lanczos)
-			}
-		} else {
-			// 不保持宽高比，直接调整尺寸
-			img = resize.Resize(options.Width, options.Height, img, resize.Lanczos3)
+	if img, err = resizeImage(img, options); err != nil {
+		return nil, err
+	}
+
+	// 执行滤镜与调整
+	if len(options.Filters) > 0 {
+		if img, err = applyFilters(img, options.Filters); err != nil {
+			return nil, err
 		}
 	}
 
-	// 创建输出文件
-	outputFile, err := os.Create(outputPath)
+	// 叠加水印
+	if options.Watermark != nil {
+		if err := ValidateWatermark(options.Watermark); err != nil {
+			return nil, err
+		}
+		watermark := options.Watermark.normalized()
+		mark, err := s.watermarkMark(ctx, watermark)
+		if err != nil {
+			return nil, err
+		}
+		if img, err = applyWatermark(img, mark, watermark); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// 确定输出格式
+	outputFormat, err := NormalizeOutputFormat(options.OutputFormat)
 	if err != nil {
-		return nil, fmt.Errorf("无法创建输出文件: %v", err)
+		return nil, err
+	}
+	if outputFormat == "" {
+		var ok bool
+		if outputFormat, ok = encodeFormatFor(strings.ToLower(format)); !ok {
+			return nil, fmt.Errorf("不支持的图片格式: %s", format)
+		}
 	}
-	defer outputFile.Close()
 
-	// 根据格式编码图片
-	switch strings.ToLower(format) {
-	case "jpeg", "jpg":
-		quality := options.Quality
-		if quality <= 0 || quality > 100 {
-			quality = 85 // 默认质量
+	// 目标格式不支持透明时铺底
+	if opaqueFormats[outputFormat] {
+		background := defaultBackground
+		if options.Background != "" {
+			if background, err = ParseHexColor(options.Background); err != nil {
+				return nil, err
+			}
 		}
-		err = jpeg.Encode(outputFile, img, &jpeg.Options{Quality: quality})
-	case "png":
-		err = png.Encode(outputFile, img)
-	default:
-		return nil, errors.New("不支持的图片格式")
+		img = flattenImage(img, background)
+	}
+
+	// 编码前的图片，用于计算画质指标，调色板量化的损失计入指标
+	reference := img
+
+	// 调色板量化
+	if options.Colors > 0 && (outputFormat == "png" || outputFormat == "gif") {
+		img = quantizeImage(img, options.Colors, options.Dither)
 	}
 
+	// 需要保留的元数据计入目标大小
+	metadataMode, err := ParseMetadataMode(options.Metadata)
 	if err != nil {
-		return nil, fmt.Errorf("编码图片失败: %v", err)
+		return nil, err
+	}
+	payload := metadataPayload(exif, outputFormat, metadataMode)
+	if payload != nil && options.TargetSize > 0 {
+		options.TargetSize = max(options.TargetSize-int64(exifSegmentSize(payload)), 1)
 	}
 
-	// 获取压缩后文件信息
-	compressedInfo, err := os.Stat(outputPath)
+	// 根据格式编码图片
+	reportProgress(ctx, StageEncoding, 70)
+	encoded, err := encodeOutput(ctx, img, outputFormat, options)
 	if err != nil {
-		return nil, fmt.Errorf("无法获取压缩文件信息: %v", err)
+		return nil, err
 	}
 
-	// 计算压缩比例
-	compressionRatio := float64(compressedInfo.Size()) / float64(originalInfo.Size()) * 100
+	// 解码编码结果计算画质指标，低于最低 SSIM 时重试或拒绝
+	metrics, err := measureQuality(reference, encoded.Data)
+	if err != nil {
+		return nil, err
+	}
+	if encoded, metrics, err = ensureMinSSIM(ctx, img, reference, outputFormat, options, encoded, metrics); err != nil {
+		return nil, err
+	}
+	// 按实际编码的图片计算感知哈希，用于查找相似图片，目标大小模式缩小尺寸后同样对应输出
+	hashes := ComputeHashes(encoded.Image)
 
-	result := &CompressResult{
-		OriginalSize:   originalInfo.Size(),
-		CompressedSize: compressedInfo.Size(),
-		Filename:       filepath.Base(outputPath),
-		CompressionURL: fmt.Sprintf("/api/v1/images/download/%s", filepath.Base(outputPath)),
-		Ratio:          fmt.Sprintf("%.1f%%", compressionRatio),
+	output := encoded.Data
+	if payload != nil {
+		if output, err = insertJPEGExif(output, payload); err != nil {
+			return nil, err
+		}
 	}
 
-	return result, nil
-}
+	// 尺寸与方向均未变化且重新编码后更大时保留原始文件，未指定输出格式时的隐式转换（如 WebP 转 PNG）同样适用
+	// 原始文件带有元数据时，只有保留全部元数据的模式才允许
+	keptOriginal := false
+	if int64(len(output)) >= originalSize && (outputFormat == format || options.OutputFormat == "") && !modified &&
+		img.Bounds().Size() == originalBounds.Size() &&
+		(metadataMode == MetadataKeep || !containsMetadata(original, format)) {
+		output = original
+		keptOriginal = true
+		if outputFormat != format {
+			outputKey = keptOriginalFilename(outputKey, format)
+			outputFormat = format
+		}
+		if metrics, err = measureQuality(reference, original); err != nil {
+			return nil, err
+		}
+	}
 
-// GenerateUniqueFilename 生成唯一文件名
-func GenerateUniqueFilename(originalFilename string) string {
-	ext := filepath.Ext(originalFilename)
-	name := strings.TrimSuffix(originalFilename, ext)
-	return fmt.Sprintf("%s_compressed_%d%s", name, generateTimestamp(), ext)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// 写入输出文件
+	contentType := "image/" + outputFormat
+	if err := s.compressed.Put(ctx, outputKey, bytes.NewReader(output), int64(len(output)), contentType); err != nil {
+		return nil, fmt.Errorf("无法创建输出文件: %v", err)
+	}
+
+	result := newCompressResult(originalSize, int64(len(output)), outputKey)
+	result.Quality = encoded.Quality
+	result.Iterations = encoded.Iterations
+	result.KeptOriginal = keptOriginal
+	result.LargerThanOriginal = int64(len(output)) > originalSize
+	result.Width, result.Height = encoded.Width, encoded.Height
+	result.SHA256 = contentHash(output)
+	result.Hashes = &hashes
+	result.Metrics = metrics
+	return &result, nil
 }
 
-// generateTimestamp 生成时间戳
-func generateTimestamp() int64 {
-	return time.Now().Unix()
+// newCompressResult 根据文件大小生成压缩结果
+func newCompressResult(originalSize, compressedSize int64, filename string) CompressResult {
+	// 计算压缩比例
+	compressionRatio := float64(compressedSize) / float64(originalSize) * 100
+
+	return CompressResult{
+		OriginalSize:   originalSize,
+		CompressedSize: compressedSize,
+		Filename:       filename,
+		CompressionURL: fmt.Sprintf("/api/v1/images/download/%s", filename),
+		Ratio:          fmt.Sprintf("%.1f%%", compressionRatio),
+	}
 }
 
 // CopyFile 复制文件
@@ -0,0 +1,330 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"mini-toolbox/storage"
+	"mini-toolbox/utils"
+)
+
+// maxCachedResults 内存中最多缓存的压缩结果数，超出后随机淘汰
+const maxCachedResults = 10000
+
+// StoredUpload 保存的原始文件，每次上传使用新的随机 ID，相同内容共用一个按 SHA-256 命名的文件
+type StoredUpload struct {
+	ID       string `json:"id"`       // 随机生成的上传 ID
+	Key      string `json:"-"`        // 存储中的文件名，为 <SHA-256><扩展名>
+	Path     string `json:"path"`     // 对外使用的文件名，为 <id><扩展名>
+	SHA256   string `json:"-"`        // 文件内容的 SHA-256
+	Name     string `json:"name"`     // 清理后的显示文件名
+	Existing bool   `json:"existing"` // 相同内容的文件已存在，本次没有写入
+}
+
+// resultCache 压缩结果缓存，键由原始文件哈希与规范化后的压缩选项计算
+type resultCache struct {
+	mu      sync.Mutex
+	results map[string]CompressResult
+}
+
+func newResultCache() *resultCache {
+	return &resultCache{results: make(map[string]CompressResult)}
+}
+
+func (c *resultCache) get(key string) (CompressResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result, ok := c.results[key]
+	return result, ok
+}
+
+func (c *resultCache) put(key string, result CompressResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.results) >= maxCachedResults {
+		for k := range c.results {
+			delete(c.results, k)
+			break
+		}
+	}
+	c.results[key] = result
+}
+
+func (c *resultCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.results, key)
+}
+
+// sourceIndex 旧版上传文件名到内容哈希的索引，避免重复读取文件计算，超出容量后随机淘汰
+type sourceIndex struct {
+	mu     sync.Mutex
+	hashes map[string]string // 上传文件名 -> SHA-256
+}
+
+func newSourceIndex() *sourceIndex {
+	return &sourceIndex{hashes: make(map[string]string)}
+}
+
+func (i *sourceIndex) get(key string) (string, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	hash, ok := i.hashes[key]
+	return hash, ok
+}
+
+// add 登记上传文件的内容哈希
+func (i *sourceIndex) add(key, hash string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if len(i.hashes) >= maxCachedResults {
+		for k := range i.hashes {
+			delete(i.hashes, k)
+			break
+		}
+	}
+	i.hashes[key] = hash
+}
+
+// contentHash 计算内容的 SHA-256
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hashFromKey 从按内容寻址保存的文件名中取出哈希，不是这类文件名时返回空字符串
+func hashFromKey(key string) string {
+	stem := strings.TrimSuffix(key, filepath.Ext(key))
+	if len(stem) != sha256.Size*2 {
+		return ""
+	}
+	if _, err := hex.DecodeString(stem); err != nil {
+		return ""
+	}
+	return stem
+}
+
+// SaveUpload 以内容的 SHA-256 命名保存原始文件，相同内容的文件已存在时不再写入
+// 每次上传生成新的随机 ID，由调用方登记到图片目录中
+func (s *DefaultImageService) SaveUpload(ctx context.Context, filename, contentType string, data []byte) (*StoredUpload, error) {
+	id := utils.NewID()
+	ext := strings.ToLower(filepath.Ext(filename))
+	hash := contentHash(data)
+	upload := &StoredUpload{
+		ID:     id,
+		Key:    hash + ext,
+		Path:   id + ext,
+		SHA256: hash,
+		Name:   utils.SanitizeFilename(filename),
+	}
+	_, err := s.uploads.Stat(ctx, upload.Key)
+	if err == nil {
+		upload.Existing = true
+		return upload, nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+	if err := s.uploads.Put(ctx, upload.Key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// sourceHash 返回上传文件的内容哈希，文件名不含哈希时读取文件计算
+func (s *DefaultImageService) sourceHash(ctx context.Context, key string) (string, error) {
+	if hash := hashFromKey(key); hash != "" {
+		return hash, nil
+	}
+	if hash, ok := s.sources.get(key); ok {
+		return hash, nil
+	}
+	data, err := storage.ReadAll(ctx, s.uploads, key)
+	if err != nil {
+		return "", err
+	}
+	hash := contentHash(data)
+	s.sources.add(key, hash)
+	return hash, nil
+}
+
+// normalizeOptions 规范化压缩选项，使效果相同的选项得到相同的缓存键
+func normalizeOptions(options CompressionOption) CompressionOption {
+	options.OutputFormat, _ = NormalizeOutputFormat(options.OutputFormat)
+	if options.Quality <= 0 || options.Quality > 100 {
+		options.Quality = 85
+	}
+	if options.Background != "" {
+		if bg, err := ParseHexColor(options.Background); err == nil {
+			options.Background = fmt.Sprintf("#%02x%02x%02x%02x", bg.R, bg.G, bg.B, bg.A)
+		}
+	}
+	if options.TargetSize <= 0 {
+		options.TargetSize = 0
+		options.AllowDownscale = false
+	}
+	options.PNGCompression = strings.ToLower(strings.TrimSpace(options.PNGCompression))
+	if options.PNGCompression == "" {
+		options.PNGCompression = "default"
+	}
+	if options.Colors == 0 {
+		options.Dither = false
+	}
+	options.Metadata, _ = ParseMetadataMode(options.Metadata)
+
+	options.Mode, _ = ParseResizeMode(options.Mode)
+	options.Filter = strings.ToLower(strings.TrimSpace(options.Filter))
+	if options.Filter == "" {
+		options.Filter = "lanczos"
+	}
+	options.Anchor = strings.ToLower(strings.TrimSpace(options.Anchor))
+	if options.Mode != ResizeFill {
+		options.Anchor = ""
+	} else if options.Anchor == "" {
+		options.Anchor = "center"
+	}
+	if options.Mode != ResizePercentage {
+		options.Percentage = 0
+	}
+	if options.Mode != ResizeMaxMegapixels {
+		options.MaxMegapixels = 0
+	}
+	if options.Mode != "" {
+		options.KeepAspect = false
+	}
+	if len(options.Transforms) == 0 {
+		options.Transforms = nil
+	}
+	if len(options.Filters) == 0 {
+		options.Filters = nil
+	} else {
+		filters := make([]FilterOp, len(options.Filters))
+		for i, op := range options.Filters {
+			filters[i] = op.normalized()
+		}
+		options.Filters = filters
+	}
+	if options.Watermark != nil {
+		watermark := options.Watermark.normalized()
+		options.Watermark = &watermark
+	}
+	return options
+}
+
+// resultCacheKey 根据原始文件哈希与压缩选项计算缓存键
+func resultCacheKey(sourceHash string, options CompressionOption) string {
+	encoded, _ := json.Marshal(normalizeOptions(options))
+	return contentHash([]byte(sourceHash + "\n" + string(encoded)))
+}
+
+// ResultCacheKey 返回原始文件与压缩选项对应的缓存键，可用作输出内容的 ETag
+func ResultCacheKey(sourceHash string, options CompressionOption) string {
+	return resultCacheKey(sourceHash, options)
+}
+
+// CompressCached 压缩图片并缓存结果
+// 输出文件名由缓存键决定，相同原始文件与等效选项的请求直接返回已有结果
+func (s *DefaultImageService) CompressCached(ctx context.Context, inputKey string, options CompressionOption) (*CompressResult, error) {
+	return s.compressCachedWith(ctx, inputKey, options, func() (*sourceImage, error) {
+		return s.loadSource(ctx, inputKey)
+	})
+}
+
+// compressCachedWith 与 CompressCached 相同，未命中缓存时通过 load 获取解码后的原始图片
+func (s *DefaultImageService) compressCachedWith(ctx context.Context, inputKey string, options CompressionOption, load func() (*sourceImage, error)) (*CompressResult, error) {
+	sourceHash, err := s.sourceHash(ctx, inputKey)
+	if err != nil {
+		return nil, err
+	}
+	// 水印图片同样按内容参与缓存键，相同水印的不同上传可复用结果
+	keyOptions := options
+	if options.Watermark != nil && options.Watermark.Image != "" {
+		watermarkHash, err := s.sourceHash(ctx, options.Watermark.Image)
+		if err != nil {
+			return nil, err
+		}
+		watermark := *options.Watermark
+		watermark.Image = watermarkHash
+		keyOptions.Watermark = &watermark
+	}
+
+	cacheKey := resultCacheKey(sourceHash, keyOptions)
+	outputKey := OutputFilename(cacheKey[:32]+filepath.Ext(inputKey), options.OutputFormat)
+
+	info, existingKey, err := s.statOutput(ctx, inputKey, outputKey, options.OutputFormat)
+	switch {
+	case err == nil:
+		result, ok := s.cache.get(cacheKey)
+		if !ok {
+			// 重启后内存缓存为空，根据已有文件重建结果
+			original, statErr := s.uploads.Stat(ctx, inputKey)
+			if statErr != nil {
+				return nil, statErr
+			}
+			result = newCompressResult(original.Size, info.Size, existingKey)
+			result.SourceSHA256 = sourceHash
+			result.KeptOriginal = existingKey != outputKey
+			result.LargerThanOriginal = info.Size > original.Size
+			result.Width, result.Height, result.Hashes = s.describeOutput(ctx, existingKey)
+			s.cache.put(cacheKey, result)
+		}
+		result.Cached = true
+		return &result, nil
+	case errors.Is(err, storage.ErrNotFound):
+		s.cache.remove(cacheKey)
+	default:
+		return nil, err
+	}
+
+	source, err := load()
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.compressSource(ctx, source, outputKey, options)
+	if err != nil {
+		return nil, err
+	}
+	result.SourceSHA256 = sourceHash
+	s.cache.put(cacheKey, *result)
+	return result, nil
+}
+
+// statOutput 查找已有的压缩结果
+// 未指定输出格式时，隐式转换后更大的结果以原始格式保存，需要同时查找原始扩展名的文件
+func (s *DefaultImageService) statOutput(ctx context.Context, inputKey, outputKey, outputFormat string) (storage.ObjectInfo, string, error) {
+	info, err := s.compressed.Stat(ctx, outputKey)
+	if !errors.Is(err, storage.ErrNotFound) || outputFormat != "" {
+		return info, outputKey, err
+	}
+	source, ok := lookupFormat(inputKey)
+	if !ok || source.Decoder == source.EncodeAs {
+		return info, outputKey, err
+	}
+	keptKey := keptOriginalFilename(outputKey, source.Decoder)
+	if keptInfo, keptErr := s.compressed.Stat(ctx, keptKey); keptErr == nil {
+		return keptInfo, keptKey, nil
+	}
+	return info, outputKey, err
+}
+
+// describeOutput 解码已有的压缩结果，返回尺寸与感知哈希，失败时返回零值
+func (s *DefaultImageService) describeOutput(ctx context.Context, key string) (int, int, *ImageHashes) {
+	data, err := storage.ReadAll(ctx, s.compressed, key)
+	if err != nil {
+		return 0, 0, nil
+	}
+	img, _, err := decodeImage(data)
+	if err != nil {
+		return 0, 0, nil
+	}
+	hashes := ComputeHashes(img)
+	return img.Bounds().Dx(), img.Bounds().Dy(), &hashes
+}
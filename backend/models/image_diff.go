@@ -0,0 +1,143 @@
+package models
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	DefaultDiffThreshold = 10   // 默认差异阈值，通道误差超过该值的像素计为不同
+	maxDiffDimension     = 2048 // 比较时的最大宽高，超出时等比缩小
+	diffCompositeGap     = 8    // 拼接图中各部分的间距
+)
+
+// diffGapColor 拼接图间距的颜色
+var diffGapColor = color.NRGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}
+
+// DiffStats 两张图片的差异统计
+type DiffStats struct {
+	Width       int            `json:"width"`       // 比较时的宽度
+	Height      int            `json:"height"`      // 比较时的高度
+	Resized     bool           `json:"resized"`     // 第二张图片是否缩放或裁剪到第一张图片的尺寸
+	Threshold   int            `json:"threshold"`   // 差异阈值
+	DiffPixels  int            `json:"diffPixels"`  // 通道误差超过阈值的像素数
+	DiffPercent float64        `json:"diffPercent"` // 差异像素占比（%）
+	MeanError   float64        `json:"meanError"`   // 平均绝对误差 (0-255)
+	Metrics     QualityMetrics `json:"metrics"`     // PSNR、SSIM 与各通道最大误差
+}
+
+// ImageDiff 差异比较结果，热力图与拼接图为 PNG 编码的内容
+type ImageDiff struct {
+	Stats     DiffStats `json:"stats"`
+	Heatmap   []byte    `json:"-"` // 差异热力图，阈值内的像素显示为变暗的灰度原图
+	Composite []byte    `json:"-"` // 第一张图、第二张图与热力图的左右拼接
+}
+
+// ValidateDiffThreshold 校验差异阈值
+func ValidateDiffThreshold(threshold int) error {
+	if threshold < 0 || threshold > 255 {
+		return fmt.Errorf("%w: 差异阈值必须在 0-255 之间", ErrInvalidInput)
+	}
+	return nil
+}
+
+// DiffImages 解码两张图片并生成差异热力图、拼接图与统计
+// 第二张图片按第一张图片的尺寸居中裁剪缩放对齐，超过 2048 像素的图片先等比缩小
+func (s *DefaultImageService) DiffImages(first, second []byte, threshold int) (*ImageDiff, error) {
+	if err := ValidateDiffThreshold(threshold); err != nil {
+		return nil, err
+	}
+	a, _, _, err := decodeOriented(first)
+	if err != nil {
+		return nil, fmt.Errorf("第一张图片: %w", err)
+	}
+	b, _, _, err := decodeOriented(second)
+	if err != nil {
+		return nil, fmt.Errorf("第二张图片: %w", err)
+	}
+
+	bounds := a.Bounds()
+	if bounds.Dx() > maxDiffDimension || bounds.Dy() > maxDiffDimension {
+		a = imaging.Fit(a, maxDiffDimension, maxDiffDimension, imaging.Lanczos)
+		bounds = a.Bounds()
+	}
+	width, height := bounds.Dx(), bounds.Dy()
+	resized := b.Bounds().Size() != bounds.Size()
+	if resized {
+		b = imaging.Fill(b, width, height, imaging.Center, imaging.Lanczos)
+	}
+	left, right := imaging.Clone(a), imaging.Clone(b)
+
+	stats := DiffStats{Width: width, Height: height, Resized: resized, Threshold: threshold}
+	stats.Metrics = *compareImages(left, right)
+
+	// 每个像素取各通道误差的最大值，按整张图片的最大误差归一化着色
+	// 透明像素与 PSNR、SSIM 一致按白色背景合成后比较
+	deltas := make([]int, width*height)
+	peak, total := 0, 0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			pa, pb := left.Pix[left.PixOffset(x, y):], right.Pix[right.PixOffset(x, y):]
+			ra, ga, ba := overWhite(pa)
+			rb, gb, bb := overWhite(pb)
+			e := absInt(int(pa[3]) - int(pb[3]))
+			for _, d := range []float64{ra - rb, ga - gb, ba - bb} {
+				e = max(e, int(math.Round(math.Abs(d))))
+			}
+			deltas[y*width+x] = e
+			peak = max(peak, e)
+			total += e
+			if e > threshold {
+				stats.DiffPixels++
+			}
+		}
+	}
+	pixels := float64(width * height)
+	stats.DiffPercent = math.Round(float64(stats.DiffPixels)/pixels*10000) / 100
+	stats.MeanError = math.Round(float64(total)/pixels*100) / 100
+
+	heatmap := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			e := deltas[y*width+x]
+			if e > threshold {
+				heatmap.SetNRGBA(x, y, heatColor(float64(e)/float64(peak)))
+				continue
+			}
+			// 阈值内的像素显示为亮度减半的灰度原图，便于定位
+			r, g, b := overWhite(left.Pix[left.PixOffset(x, y):])
+			v := uint8((0.299*r + 0.587*g + 0.114*b) / 2)
+			heatmap.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: v, A: 0xff})
+		}
+	}
+
+	composite := image.NewNRGBA(image.Rect(0, 0, 3*width+2*diffCompositeGap, height))
+	draw.Draw(composite, composite.Bounds(), &image.Uniform{C: diffGapColor}, image.Point{}, draw.Src)
+	for i, part := range []*image.NRGBA{left, right, heatmap} {
+		offset := image.Pt(i*(width+diffCompositeGap), 0)
+		draw.Draw(composite, part.Bounds().Add(offset), part, image.Point{}, draw.Over)
+	}
+
+	diff := &ImageDiff{Stats: stats}
+	if diff.Heatmap, err = encodeToBytes(heatmap, "png", encodeParams{}); err != nil {
+		return nil, err
+	}
+	if diff.Composite, err = encodeToBytes(composite, "png", encodeParams{}); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// heatColor 将 0-1 的差异程度映射为蓝、青、绿、黄、红渐变色
+func heatColor(t float64) color.NRGBA {
+	t = math.Max(0, math.Min(1, t))
+	channel := func(center float64) uint8 {
+		return uint8(255 * math.Max(0, math.Min(1, 1.5-math.Abs(4*t-center))))
+	}
+	return color.NRGBA{R: channel(3), G: channel(2), B: channel(1), A: 0xff}
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestDiffImages(t *testing.T) {
+	service := &DefaultImageService{}
+	base := newBlockImage(32, 24, 4)
+	changed := newBlockImage(32, 24, 4)
+	for _, p := range []image.Point{{0, 0}, {5, 7}, {31, 23}} {
+		changed.SetNRGBA(p.X, p.Y, color.NRGBA{R: 255 - base.Pix[base.PixOffset(p.X, p.Y)], A: 255})
+	}
+	slight := shiftImage(base, 5)
+
+	tests := []struct {
+		name       string
+		second     image.Image
+		threshold  int
+		diffPixels int // 为负数表示不检查
+		resized    bool
+		psnr       float64 // 为 0 表示不检查
+	}{
+		{"相同图片", base, DefaultDiffThreshold, 0, false, maxPSNR},
+		{"修改 3 个像素", changed, 0, 3, false, 0},
+		{"误差在阈值内", slight, DefaultDiffThreshold, 0, false, 0},
+		{"误差超过阈值", slight, 4, 32 * 24, false, 0},
+		{"尺寸不同时缩放到第一张图片", newBlockImage(64, 48, 4), DefaultDiffThreshold, -1, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := service.DiffImages(encodeTestImage(t, base, "png"), encodeTestImage(t, tt.second, "png"), tt.threshold)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stats := diff.Stats
+			if stats.Width != 32 || stats.Height != 24 || stats.Resized != tt.resized {
+				t.Errorf("尺寸 = %dx%d resized=%v, want 32x24 resized=%v", stats.Width, stats.Height, stats.Resized, tt.resized)
+			}
+			if tt.diffPixels >= 0 && stats.DiffPixels != tt.diffPixels {
+				t.Errorf("DiffPixels = %d, want %d", stats.DiffPixels, tt.diffPixels)
+			}
+			if tt.psnr != 0 && stats.Metrics.PSNR != tt.psnr {
+				t.Errorf("PSNR = %v, want %v", stats.Metrics.PSNR, tt.psnr)
+			}
+
+			heatmap, err := png.Decode(bytes.NewReader(diff.Heatmap))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := heatmap.Bounds().Size(); size != image.Pt(32, 24) {
+				t.Errorf("热力图尺寸 = %v, want (32,24)", size)
+			}
+			composite, err := png.Decode(bytes.NewReader(diff.Composite))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := composite.Bounds().Size(); size != image.Pt(3*32+2*diffCompositeGap, 24) {
+				t.Errorf("拼接图尺寸 = %v", size)
+			}
+		})
+	}
+}
+
+func TestDiffImagesInvalid(t *testing.T) {
+	service := &DefaultImageService{}
+	data := encodeTestImage(t, newBlockImage(8, 8, 1), "png")
+	tests := []struct {
+		name          string
+		first, second []byte
+		threshold     int
+	}{
+		{"阈值为负数", data, data, -1},
+		{"阈值超过 255", data, data, 256},
+		{"第一张图片无法解码", []byte("bad"), data, 0},
+		{"第二张图片无法解码", data, []byte("bad"), 0},
+	}
+	for _, tt := range tests {
+		if _, err := service.DiffImages(tt.first, tt.second, tt.threshold); err == nil {
+			t.Errorf("%s: DiffImages 应返回错误", tt.name)
+		}
+	}
+}
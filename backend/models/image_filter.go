@@ -0,0 +1,316 @@
+package models
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// 滤镜与调整操作类型
+const (
+	FilterBrightness = "brightness" // 亮度，value 为百分比 (-100-100)
+	FilterContrast   = "contrast"   // 对比度，value 为百分比 (-100-100)
+	FilterGamma      = "gamma"      // 伽马校正，value (0.1-10)，小于 1 变暗，大于 1 变亮
+	FilterSaturation = "saturation" // 饱和度，value 为百分比 (-100-100)
+	FilterHue        = "hue"        // 色相旋转，value 为角度 (-180-180)
+	FilterGrayscale  = "grayscale"  // 灰度
+	FilterInvert     = "invert"     // 反色
+	FilterSepia      = "sepia"      // 复古棕褐色，value 为强度百分比 (0-100)，未设置时为 100
+	FilterBlur       = "blur"       // 高斯模糊，sigma (0.1-50)
+	FilterSharpen    = "sharpen"    // 锐化，sigma (0.1-10)
+	FilterUnsharp    = "unsharp"    // USM 锐化，sigma (0.1-10)、amount (0.1-5，默认 1)、threshold (0-255)
+)
+
+// maxFilters 单次请求允许的最大滤镜数
+const maxFilters = 20
+
+// FilterOp 滤镜与调整操作，在调整尺寸后、叠加水印前按顺序执行
+type FilterOp struct {
+	Type      string   `json:"type"`                // 操作类型
+	Value     *float64 `json:"value,omitempty"`     // brightness/contrast/gamma/saturation/hue/sepia 的参数，为空表示未设置
+	Sigma     float64  `json:"sigma,omitempty"`     // blur/sharpen/unsharp 的高斯半径
+	Amount    float64  `json:"amount,omitempty"`    // unsharp: 锐化强度
+	Threshold float64  `json:"threshold,omitempty"` // unsharp: 与模糊结果的差值超过该值才锐化
+}
+
+// filterRange 参数取值范围
+type filterRange struct {
+	name     string
+	min, max float64
+}
+
+// check 校验参数是否在范围内
+func (r filterRange) check(value float64) error {
+	if math.IsNaN(value) || value < r.min || value > r.max {
+		return fmt.Errorf("%w: %s 必须在 %g-%g 之间", ErrInvalidInput, r.name, r.min, r.max)
+	}
+	return nil
+}
+
+// 各参数的取值范围
+var (
+	percentRange   = filterRange{"value", -100, 100}
+	gammaRange     = filterRange{"value", 0.1, 10}
+	hueRange       = filterRange{"value", -180, 180}
+	sepiaRange     = filterRange{"value", 0, 100}
+	blurRange      = filterRange{"sigma", 0.1, 50}
+	sharpenRange   = filterRange{"sigma", 0.1, 10}
+	amountRange    = filterRange{"amount", 0.1, 5}
+	thresholdRange = filterRange{"threshold", 0, 255}
+)
+
+// ValidateFilters 校验滤镜参数
+func ValidateFilters(ops []FilterOp) error {
+	if len(ops) > maxFilters {
+		return fmt.Errorf("%w: 滤镜最多 %d 个", ErrInvalidInput, maxFilters)
+	}
+	for i, op := range ops {
+		if err := op.validate(); err != nil {
+			return fmt.Errorf("第 %d 个滤镜: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// normalized 返回填充默认值后的滤镜，用于计算缓存键与执行
+func (op FilterOp) normalized() FilterOp {
+	op.Type = strings.ToLower(strings.TrimSpace(op.Type))
+	switch op.Type {
+	case FilterSepia:
+		// 只有未设置时使用默认强度，value 为 0 表示不产生效果
+		if op.Value == nil {
+			strength := 100.0
+			op.Value = &strength
+		}
+	case FilterUnsharp:
+		if op.Amount == 0 {
+			op.Amount = 1
+		}
+	}
+	return op
+}
+
+// value 返回 value 参数，未设置时为 0
+func (op FilterOp) value() float64 {
+	if op.Value == nil {
+		return 0
+	}
+	return *op.Value
+}
+
+// validate 校验单个滤镜的参数
+func (op FilterOp) validate() error {
+	op = op.normalized()
+	switch op.Type {
+	case FilterBrightness, FilterContrast, FilterSaturation:
+		return percentRange.check(op.value())
+	case FilterGamma:
+		return gammaRange.check(op.value())
+	case FilterHue:
+		return hueRange.check(op.value())
+	case FilterSepia:
+		return sepiaRange.check(op.value())
+	case FilterGrayscale, FilterInvert:
+		return nil
+	case FilterBlur:
+		return blurRange.check(op.Sigma)
+	case FilterSharpen:
+		return sharpenRange.check(op.Sigma)
+	case FilterUnsharp:
+		if err := sharpenRange.check(op.Sigma); err != nil {
+			return err
+		}
+		if err := amountRange.check(op.Amount); err != nil {
+			return err
+		}
+		return thresholdRange.check(op.Threshold)
+	}
+	return fmt.Errorf("%w: 未知的滤镜 %s", ErrInvalidInput, op.Type)
+}
+
+// filterCost 估算滤镜的开销，以遍历整张图片的次数计
+// 逐像素调整为 1，卷积类滤镜的核宽度与 sigma 成正比，按 1+sigma 向上取整计算
+func filterCost(op FilterOp) int {
+	switch op.normalized().Type {
+	case FilterBlur, FilterSharpen, FilterUnsharp:
+		return 1 + int(math.Ceil(op.Sigma))
+	default:
+		return 1
+	}
+}
+
+// applyFilters 按顺序执行滤镜
+func applyFilters(img image.Image, ops []FilterOp) (image.Image, error) {
+	if err := ValidateFilters(ops); err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		img = op.normalized().apply(img)
+	}
+	return img, nil
+}
+
+// apply 执行单个滤镜，参数已校验
+func (op FilterOp) apply(img image.Image) image.Image {
+	switch op.Type {
+	case FilterBrightness:
+		return imaging.AdjustBrightness(img, op.value())
+	case FilterContrast:
+		return imaging.AdjustContrast(img, op.value())
+	case FilterGamma:
+		return imaging.AdjustGamma(img, op.value())
+	case FilterSaturation:
+		return imaging.AdjustSaturation(img, op.value())
+	case FilterHue:
+		return adjustHue(img, op.value())
+	case FilterGrayscale:
+		return imaging.Grayscale(img)
+	case FilterInvert:
+		return imaging.Invert(img)
+	case FilterSepia:
+		return sepia(img, op.value()/100)
+	case FilterBlur:
+		return imaging.Blur(img, op.Sigma)
+	case FilterSharpen:
+		return imaging.Sharpen(img, op.Sigma)
+	case FilterUnsharp:
+		return unsharpMask(img, op.Sigma, op.Amount, op.Threshold)
+	}
+	return img
+}
+
+// adjustHue 在 HSL 空间中旋转色相
+func adjustHue(img image.Image, degrees float64) *image.NRGBA {
+	return imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		h, s, l := rgbToHSL(c.R, c.G, c.B)
+		r, g, b := hslToRGB(math.Mod(h+degrees+360, 360), s, l)
+		return color.NRGBA{R: r, G: g, B: b, A: c.A}
+	})
+}
+
+// sepia 按强度混合原色与棕褐色调
+func sepia(img image.Image, strength float64) *image.NRGBA {
+	return imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		r, g, b := float64(c.R), float64(c.G), float64(c.B)
+		tr := 0.393*r + 0.769*g + 0.189*b
+		tg := 0.349*r + 0.686*g + 0.168*b
+		tb := 0.272*r + 0.534*g + 0.131*b
+		return color.NRGBA{
+			R: clampChannel(r + (tr-r)*strength),
+			G: clampChannel(g + (tg-g)*strength),
+			B: clampChannel(b + (tb-b)*strength),
+			A: c.A,
+		}
+	})
+}
+
+// unsharpMask USM 锐化：原图加上与高斯模糊结果之差乘以强度，差值不超过阈值的像素保持不变
+func unsharpMask(img image.Image, sigma, amount, threshold float64) *image.NRGBA {
+	src := imaging.Clone(img)
+	blurred := imaging.Blur(src, sigma)
+	dst := image.NewNRGBA(src.Bounds())
+	for i := 0; i < len(src.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			v := float64(src.Pix[i+c])
+			diff := v - float64(blurred.Pix[i+c])
+			if math.Abs(diff) > threshold {
+				v += diff * amount
+			}
+			dst.Pix[i+c] = clampChannel(v)
+		}
+		dst.Pix[i+3] = src.Pix[i+3]
+	}
+	return dst
+}
+
+// clampChannel 将通道值四舍五入并限制在 0-255
+func clampChannel(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(v))))
+}
+
+// rgbToHSL 将 RGB 转换为 HSL，h 为角度 (0-360)，s、l 为 0-1
+func rgbToHSL(r8, g8, b8 uint8) (h, s, l float64) {
+	r, g, b := float64(r8)/255, float64(g8)/255, float64(b8)/255
+	maxC, minC := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	l = (maxC + minC) / 2
+	delta := maxC - minC
+	if delta == 0 {
+		return 0, 0, l
+	}
+	s = delta / (1 - math.Abs(2*l-1))
+	switch maxC {
+	case r:
+		h = math.Mod((g-b)/delta+6, 6)
+	case g:
+		h = (b-r)/delta + 2
+	default:
+		h = (r-g)/delta + 4
+	}
+	return h * 60, s, l
+}
+
+// hslToRGB 将 HSL 转换为 RGB
+func hslToRGB(h, s, l float64) (r, g, b uint8) {
+	chroma := (1 - math.Abs(2*l-1)) * s
+	x := chroma * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - chroma/2
+	var rf, gf, bf float64
+	switch {
+	case h < 60:
+		rf, gf = chroma, x
+	case h < 120:
+		rf, gf = x, chroma
+	case h < 180:
+		gf, bf = chroma, x
+	case h < 240:
+		gf, bf = x, chroma
+	case h < 300:
+		rf, bf = x, chroma
+	default:
+		rf, bf = chroma, x
+	}
+	return clampChannel((rf + m) * 255), clampChannel((gf + m) * 255), clampChannel((bf + m) * 255)
+}
+
+// ParseFilterToken 解析 URL 中的滤镜参数，如 grayscale、blur:2、unsharp:1.5:1:5
+// 冒号后依次为该滤镜的参数：value 类滤镜为 value，blur/sharpen 为 sigma，unsharp 为 sigma、amount、threshold
+func ParseFilterToken(token string) (FilterOp, error) {
+	parts := strings.Split(token, ":")
+	op := FilterOp{Type: strings.ToLower(parts[0])}
+	args := make([]float64, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return op, fmt.Errorf("%w: 无效的滤镜参数 %s", ErrInvalidInput, token)
+		}
+		args = append(args, v)
+	}
+
+	var fields []*float64
+	switch op.Type {
+	case FilterBrightness, FilterContrast, FilterGamma, FilterSaturation, FilterHue, FilterSepia:
+		if len(args) > 0 {
+			op.Value = new(float64)
+		}
+		fields = []*float64{op.Value}
+	case FilterBlur, FilterSharpen:
+		fields = []*float64{&op.Sigma}
+	case FilterUnsharp:
+		fields = []*float64{&op.Sigma, &op.Amount, &op.Threshold}
+	}
+	if len(args) > len(fields) {
+		return op, fmt.Errorf("%w: 滤镜参数过多 %s", ErrInvalidInput, token)
+	}
+	for i, v := range args {
+		*fields[i] = v
+	}
+	if err := op.validate(); err != nil {
+		return op, err
+	}
+	return op, nil
+}
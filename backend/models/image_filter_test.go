@@ -0,0 +1,108 @@
+package models
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestParseFilterToken(t *testing.T) {
+	value := func(v float64) *float64 { return &v }
+	tests := []struct {
+		token string
+		want  FilterOp
+		valid bool
+	}{
+		{"grayscale", FilterOp{Type: FilterGrayscale}, true},
+		{"sepia", FilterOp{Type: FilterSepia}, true},
+		{"sepia:0", FilterOp{Type: FilterSepia, Value: value(0)}, true},
+		{"brightness:-20", FilterOp{Type: FilterBrightness, Value: value(-20)}, true},
+		{"blur:2", FilterOp{Type: FilterBlur, Sigma: 2}, true},
+		{"unsharp:1.5:2:5", FilterOp{Type: FilterUnsharp, Sigma: 1.5, Amount: 2, Threshold: 5}, true},
+		{"blur", FilterOp{}, false},
+		{"blur:1:2", FilterOp{}, false},
+		{"brightness:200", FilterOp{}, false},
+		{"hue:x", FilterOp{}, false},
+		{"unsharp:1:9", FilterOp{}, false},
+		{"foo", FilterOp{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseFilterToken(tt.token)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParseFilterToken(%q) 错误 = %v, want valid %v", tt.token, err, tt.valid)
+			continue
+		}
+		if !tt.valid {
+			continue
+		}
+		if got.Type != tt.want.Type || got.Sigma != tt.want.Sigma || got.Amount != tt.want.Amount ||
+			got.Threshold != tt.want.Threshold || (got.Value == nil) != (tt.want.Value == nil) ||
+			(got.Value != nil && *got.Value != *tt.want.Value) {
+			t.Errorf("ParseFilterToken(%q) = %+v, want %+v", tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestFilterCost(t *testing.T) {
+	tests := []struct {
+		op   FilterOp
+		want int
+	}{
+		{FilterOp{Type: FilterGrayscale}, 1},
+		{FilterOp{Type: FilterBlur, Sigma: 0.5}, 2},
+		{FilterOp{Type: FilterBlur, Sigma: 2}, 3},
+		{FilterOp{Type: FilterUnsharp, Sigma: 10}, 11},
+		{FilterOp{Type: " SHARPEN ", Sigma: 1.2}, 3},
+	}
+	for _, tt := range tests {
+		if got := filterCost(tt.op); got != tt.want {
+			t.Errorf("filterCost(%+v) = %d, want %d", tt.op, got, tt.want)
+		}
+	}
+}
+
+func TestApplyFilters(t *testing.T) {
+	base := newUniformImage(4, 4, color.NRGBA{R: 10, G: 20, B: 30, A: 255})
+	zero := 0.0
+
+	tests := []struct {
+		name  string
+		ops   []FilterOp
+		check func(c color.NRGBA) bool
+	}{
+		{"强度为 0 的 sepia 不改变像素", []FilterOp{{Type: FilterSepia, Value: &zero}},
+			func(c color.NRGBA) bool { return c == color.NRGBA{R: 10, G: 20, B: 30, A: 255} }},
+		{"默认强度的 sepia", []FilterOp{{Type: FilterSepia}},
+			func(c color.NRGBA) bool {
+				return c != color.NRGBA{R: 10, G: 20, B: 30, A: 255} && c.R >= c.G && c.G >= c.B
+			}},
+		{"反色", []FilterOp{{Type: FilterInvert}},
+			func(c color.NRGBA) bool { return c == color.NRGBA{R: 245, G: 235, B: 225, A: 255} }},
+		{"灰度", []FilterOp{{Type: FilterGrayscale}},
+			func(c color.NRGBA) bool { return c.R == c.G && c.G == c.B }},
+		{"按顺序执行", []FilterOp{{Type: FilterInvert}, {Type: FilterInvert}},
+			func(c color.NRGBA) bool { return c == color.NRGBA{R: 10, G: 20, B: 30, A: 255} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyFilters(base, tt.ops)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := color.NRGBAModel.Convert(got.At(1, 1)).(color.NRGBA)
+			if !tt.check(c) {
+				t.Errorf("像素 = %+v", c)
+			}
+		})
+	}
+
+	t.Run("滤镜数量超过上限", func(t *testing.T) {
+		ops := make([]FilterOp, maxFilters+1)
+		for i := range ops {
+			ops[i] = FilterOp{Type: FilterInvert}
+		}
+		if _, err := applyFilters(image.Image(base), ops); err == nil {
+			t.Error("applyFilters 应返回错误")
+		}
+	})
+}
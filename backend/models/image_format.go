@@ -0,0 +1,192 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	// 注册额外的图片解码器
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// FormatInfo 图片格式说明
+type FormatInfo struct {
+	Extension string `json:"extension"` // 文件扩展名
+	Decoder   string `json:"decoder"`   // 解码器名称（image.Decode 返回的格式名）
+	EncodeAs  string `json:"encodeAs"`  // 处理后输出的格式
+}
+
+// formatTable 支持的格式及其输出格式
+// WebP 没有纯 Go 编码器，统一输出为 PNG 以保留透明通道
+var formatTable = []FormatInfo{
+	{Extension: ".jpg", Decoder: "jpeg", EncodeAs: "jpeg"},
+	{Extension: ".jpeg", Decoder: "jpeg", EncodeAs: "jpeg"},
+	{Extension: ".png", Decoder: "png", EncodeAs: "png"},
+	{Extension: ".webp", Decoder: "webp", EncodeAs: "png"},
+	{Extension: ".gif", Decoder: "gif", EncodeAs: "gif"},
+	{Extension: ".bmp", Decoder: "bmp", EncodeAs: "bmp"},
+	{Extension: ".tif", Decoder: "tiff", EncodeAs: "tiff"},
+	{Extension: ".tiff", Decoder: "tiff", EncodeAs: "tiff"},
+}
+
+// formatExtensions 输出格式对应的文件扩展名
+var formatExtensions = map[string]string{
+	"jpeg": ".jpg",
+	"png":  ".png",
+	"gif":  ".gif",
+	"bmp":  ".bmp",
+	"tiff": ".tiff",
+}
+
+// formatAliases 输出格式名称的别名
+var formatAliases = map[string]string{
+	"jpeg": "jpeg",
+	"jpg":  "jpeg",
+	"png":  "png",
+	"gif":  "gif",
+	"bmp":  "bmp",
+	"tiff": "tiff",
+	"tif":  "tiff",
+}
+
+// opaqueFormats 不支持透明通道的输出格式
+var opaqueFormats = map[string]bool{
+	"jpeg": true,
+	"bmp":  true,
+}
+
+// defaultBackground 透明像素默认铺底颜色
+var defaultBackground = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+
+// NormalizeOutputFormat 规范化输出格式名称，空字符串表示沿用原格式
+func NormalizeOutputFormat(name string) (string, error) {
+	name = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if name == "" {
+		return "", nil
+	}
+	format, ok := formatAliases[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedOutputFormat, name)
+	}
+	return format, nil
+}
+
+// ParseHexColor 解析 #RGB、#RRGGBB 或 #RRGGBBAA 格式的颜色
+func ParseHexColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("%w: %s", ErrInvalidColor, s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("%w: %s", ErrInvalidColor, s)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// flattenImage 将透明像素合成到背景色上
+func flattenImage(img image.Image, bg color.Color) image.Image {
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{C: bg}, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Over)
+	return dst
+}
+
+// lookupFormat 根据文件名查找格式说明
+func lookupFormat(filename string) (FormatInfo, bool) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, info := range formatTable {
+		if info.Extension == ext {
+			return info, true
+		}
+	}
+	return FormatInfo{}, false
+}
+
+// encodeFormatFor 根据解码器名称确定输出格式
+func encodeFormatFor(decoder string) (string, bool) {
+	for _, info := range formatTable {
+		if info.Decoder == decoder {
+			return info.EncodeAs, true
+		}
+	}
+	return "", false
+}
+
+// OutputFilename 将文件扩展名替换为处理后实际输出格式的扩展名
+// outputFormat 为空时使用该格式的默认输出格式
+func OutputFilename(filename, outputFormat string) string {
+	if outputFormat == "" {
+		info, ok := lookupFormat(filename)
+		if !ok || info.Decoder == info.EncodeAs {
+			return filename
+		}
+		outputFormat = info.EncodeAs
+	}
+	ext, ok := formatExtensions[outputFormat]
+	if !ok {
+		return filename
+	}
+	if info, found := lookupFormat(filename); found && info.Decoder == outputFormat {
+		return filename
+	}
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ext
+}
+
+// keptOriginalFilename 隐式转换格式但保留原始文件时使用的文件名，扩展名与原始格式一致
+func keptOriginalFilename(outputKey, format string) string {
+	for _, info := range formatTable {
+		if info.Decoder == format {
+			return strings.TrimSuffix(outputKey, filepath.Ext(outputKey)) + info.Extension
+		}
+	}
+	return outputKey
+}
+
+// encodeParams 编码参数
+type encodeParams struct {
+	Quality  int                  // JPEG 质量
+	PNGLevel png.CompressionLevel // PNG 压缩级别
+}
+
+// encodeImage 按指定格式编码图片
+func encodeImage(w io.Writer, img image.Image, format string, params encodeParams) error {
+	switch format {
+	case "jpeg":
+		quality := params.Quality
+		if quality <= 0 || quality > 100 {
+			quality = 85 // 默认质量
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case "png":
+		encoder := png.Encoder{CompressionLevel: params.PNGLevel}
+		return encoder.Encode(w, img)
+	case "gif":
+		// 动图只保留第一帧，调色板图片沿用自身调色板
+		return gif.Encode(w, img, &gif.Options{NumColors: 256})
+	case "bmp":
+		return bmp.Encode(w, img)
+	case "tiff":
+		return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate, Predictor: true})
+	default:
+		return errors.New("不支持的图片格式")
+	}
+}
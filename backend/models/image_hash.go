@@ -0,0 +1,249 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+)
+
+// 感知哈希算法
+const (
+	HashAverage    = "ahash" // 均值哈希：8x8 灰度图与平均值比较
+	HashDifference = "dhash" // 差值哈希：9x8 灰度图相邻像素比较
+	HashPerceptual = "phash" // DCT 哈希：32x32 灰度图低频 DCT 系数与中位数比较
+)
+
+// hashBits 每个感知哈希的位数，汉明距离的最大值
+const hashBits = 64
+
+// ImageHash 64 位感知哈希，JSON 中以 16 位十六进制字符串表示
+type ImageHash uint64
+
+// MarshalJSON 以十六进制字符串输出
+func (h ImageHash) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%016x", uint64(h)))
+}
+
+// UnmarshalJSON 解析十六进制字符串
+func (h *ImageHash) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return fmt.Errorf("无效的感知哈希: %s", s)
+	}
+	*h = ImageHash(v)
+	return nil
+}
+
+// ImageHashes 图片的三种感知哈希
+type ImageHashes struct {
+	AHash ImageHash `json:"ahash"`
+	DHash ImageHash `json:"dhash"`
+	PHash ImageHash `json:"phash"`
+}
+
+// HashDistances 两张图片各算法的汉明距离
+type HashDistances struct {
+	AHash int `json:"ahash"`
+	DHash int `json:"dhash"`
+	PHash int `json:"phash"`
+}
+
+// ParseHashAlgorithm 解析算法名称，空字符串表示 phash
+func ParseHashAlgorithm(name string) (string, error) {
+	switch name {
+	case "":
+		return HashPerceptual, nil
+	case HashAverage, HashDifference, HashPerceptual:
+		return name, nil
+	}
+	return "", fmt.Errorf("%w: 无效的哈希算法 %s，支持 ahash、dhash、phash", ErrInvalidInput, name)
+}
+
+// Get 返回指定算法的哈希，算法需已校验
+func (h ImageHashes) Get(algorithm string) ImageHash {
+	switch algorithm {
+	case HashAverage:
+		return h.AHash
+	case HashDifference:
+		return h.DHash
+	}
+	return h.PHash
+}
+
+// Distances 计算与另一张图片各算法的汉明距离
+func (h ImageHashes) Distances(other ImageHashes) HashDistances {
+	return HashDistances{
+		AHash: HammingDistance(h.AHash, other.AHash),
+		DHash: HammingDistance(h.DHash, other.DHash),
+		PHash: HammingDistance(h.PHash, other.PHash),
+	}
+}
+
+// Get 返回指定算法的距离，算法需已校验
+func (d HashDistances) Get(algorithm string) int {
+	switch algorithm {
+	case HashAverage:
+		return d.AHash
+	case HashDifference:
+		return d.DHash
+	}
+	return d.PHash
+}
+
+// HammingDistance 计算两个哈希不同的位数
+func HammingDistance(a, b ImageHash) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+// HashSimilarity 将汉明距离换算为 0-1 的相似度
+func HashSimilarity(distance int) float64 {
+	return 1 - float64(distance)/hashBits
+}
+
+// ValidateHashDistance 校验汉明距离阈值
+func ValidateHashDistance(distance int) error {
+	if distance < 0 || distance > hashBits {
+		return fmt.Errorf("%w: 汉明距离阈值必须在 0-%d 之间", ErrInvalidInput, hashBits)
+	}
+	return nil
+}
+
+// ComputeHashes 计算图片的感知哈希
+func ComputeHashes(img image.Image) ImageHashes {
+	return ImageHashes{
+		AHash: averageHash(img),
+		DHash: differenceHash(img),
+		PHash: perceptualHash(img),
+	}
+}
+
+// HashImage 解码图片并按 EXIF 方向校正后计算感知哈希
+func (s *DefaultImageService) HashImage(data []byte) (*ImageHashes, error) {
+	img, _, _, err := decodeOriented(data)
+	if err != nil {
+		return nil, err
+	}
+	hashes := ComputeHashes(img)
+	return &hashes, nil
+}
+
+// hashImageData 解码图片数据并计算感知哈希，用于已保存的压缩结果
+func hashImageData(data []byte) (*ImageHashes, error) {
+	img, _, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	hashes := ComputeHashes(img)
+	return &hashes, nil
+}
+
+// grayscale 缩放到指定尺寸并转换为灰度值，透明像素按白色背景计算
+func grayscale(img image.Image, width, height int) [][]float64 {
+	small := imaging.Resize(img, width, height, imaging.Lanczos)
+	pixels := make([][]float64, height)
+	for y := 0; y < height; y++ {
+		pixels[y] = make([]float64, width)
+		for x := 0; x < width; x++ {
+			i := small.PixOffset(x, y)
+			r, g, b, a := float64(small.Pix[i]), float64(small.Pix[i+1]), float64(small.Pix[i+2]), float64(small.Pix[i+3])/255
+			lum := 0.299*r + 0.587*g + 0.114*b
+			pixels[y][x] = lum*a + 255*(1-a)
+		}
+	}
+	return pixels
+}
+
+// averageHash 计算均值哈希
+func averageHash(img image.Image) ImageHash {
+	pixels := grayscale(img, 8, 8)
+	var sum float64
+	for _, row := range pixels {
+		for _, v := range row {
+			sum += v
+		}
+	}
+	mean := sum / 64
+
+	var hash uint64
+	for y, row := range pixels {
+		for x, v := range row {
+			if v > mean {
+				hash |= 1 << uint(y*8+x)
+			}
+		}
+	}
+	return ImageHash(hash)
+}
+
+// differenceHash 计算差值哈希
+func differenceHash(img image.Image) ImageHash {
+	pixels := grayscale(img, 9, 8)
+	var hash uint64
+	for y, row := range pixels {
+		for x := 0; x < 8; x++ {
+			if row[x] > row[x+1] {
+				hash |= 1 << uint(y*8+x)
+			}
+		}
+	}
+	return ImageHash(hash)
+}
+
+// perceptualHash 计算 DCT 哈希，只计算左上角 8x8 的低频系数
+func perceptualHash(img image.Image) ImageHash {
+	const size = 32
+	pixels := grayscale(img, size, size)
+
+	// cosines[u][x] = cos((2x+1)uπ / 2N)
+	var cosines [8][size]float64
+	for u := 0; u < 8; u++ {
+		for x := 0; x < size; x++ {
+			cosines[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * size))
+		}
+	}
+
+	// 先对行再对列做 DCT
+	var rows [size][8]float64
+	for y := 0; y < size; y++ {
+		for u := 0; u < 8; u++ {
+			var sum float64
+			for x := 0; x < size; x++ {
+				sum += pixels[y][x] * cosines[u][x]
+			}
+			rows[y][u] = sum
+		}
+	}
+	coefficients := make([]float64, 0, 64)
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			var sum float64
+			for y := 0; y < size; y++ {
+				sum += rows[y][u] * cosines[v][y]
+			}
+			coefficients = append(coefficients, sum)
+		}
+	}
+
+	// 直流分量反映整体亮度，不参与计算中位数
+	sorted := append([]float64(nil), coefficients[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for i, c := range coefficients {
+		if c > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return ImageHash(hash)
+}
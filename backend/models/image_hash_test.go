@@ -0,0 +1,104 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+func TestHammingDistance(t *testing.T) {
+	tests := []struct {
+		a, b ImageHash
+		want int
+	}{
+		{0, 0, 0},
+		{0, ^ImageHash(0), 64},
+		{0b1011, 0b0001, 2},
+		{1 << 63, 1, 2},
+	}
+	for _, tt := range tests {
+		if got := HammingDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("HammingDistance(%x, %x) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if got := HashSimilarity(16); got != 0.75 {
+		t.Errorf("HashSimilarity(16) = %v, want 0.75", got)
+	}
+}
+
+func TestComputeHashes(t *testing.T) {
+	base := newBlockImage(128, 96, 7)
+	hashes := ComputeHashes(base)
+
+	tests := []struct {
+		name       string
+		distances  HashDistances
+		minD, maxD int
+	}{
+		{"相同图片", hashes.Distances(ComputeHashes(imaging.Clone(base))), 0, 0},
+		{"缩小一半", hashes.Distances(ComputeHashes(imaging.Resize(base, 64, 48, imaging.Lanczos))), 0, 6},
+		{"轻微模糊", hashes.Distances(ComputeHashes(imaging.Blur(base, 0.8))), 0, 10},
+		{"反色", hashes.Distances(ComputeHashes(imaging.Invert(base))), 40, 64},
+		{"不同图片", hashes.Distances(ComputeHashes(newBlockImage(128, 96, 99))), 12, 64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, algorithm := range []string{HashAverage, HashDifference, HashPerceptual} {
+				d := tt.distances.Get(algorithm)
+				if d < tt.minD || d > tt.maxD {
+					t.Errorf("%s 距离 = %d, want %d-%d", algorithm, d, tt.minD, tt.maxD)
+				}
+			}
+		})
+	}
+}
+
+func TestImageHashJSON(t *testing.T) {
+	data, err := json.Marshal(ImageHash(0x1f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"000000000000001f"` {
+		t.Errorf("Marshal = %s, want \"000000000000001f\"", data)
+	}
+	var h ImageHash
+	if err := json.Unmarshal([]byte(`"fedcba9876543210"`), &h); err != nil || h != 0xfedcba9876543210 {
+		t.Errorf("Unmarshal = %x, %v", h, err)
+	}
+	if err := json.Unmarshal([]byte(`"xyz"`), &h); err == nil {
+		t.Error("Unmarshal 无效的哈希应返回错误")
+	}
+}
+
+func TestParseHashAlgorithm(t *testing.T) {
+	tests := []struct {
+		name, want string
+		valid      bool
+	}{
+		{"", HashPerceptual, true},
+		{"ahash", HashAverage, true},
+		{"dhash", HashDifference, true},
+		{"phash", HashPerceptual, true},
+		{"md5", "", false},
+	}
+	for _, tt := range tests {
+		got, err := ParseHashAlgorithm(tt.name)
+		if (err == nil) != tt.valid || got != tt.want {
+			t.Errorf("ParseHashAlgorithm(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestValidateHashDistance(t *testing.T) {
+	for _, d := range []int{0, 10, 64} {
+		if err := ValidateHashDistance(d); err != nil {
+			t.Errorf("ValidateHashDistance(%d) = %v", d, err)
+		}
+	}
+	for _, d := range []int{-1, 65} {
+		if err := ValidateHashDistance(d); err == nil {
+			t.Errorf("ValidateHashDistance(%d) 应返回错误", d)
+		}
+	}
+}
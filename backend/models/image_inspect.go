@@ -0,0 +1,216 @@
+package models
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxInspectSize 检查图片时读取的最大字节数
+const maxInspectSize = 64 * 1024 * 1024
+
+// DPI 图片分辨率
+type DPI struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// ImageInfo 图片元信息
+type ImageInfo struct {
+	Width       int               `json:"width"`          // 宽度（像素）
+	Height      int               `json:"height"`         // 高度（像素）
+	Format      string            `json:"format"`         // 解码得到的格式
+	ColorModel  string            `json:"colorModel"`     // 颜色模型
+	HasAlpha    bool              `json:"hasAlpha"`       // 是否包含透明像素
+	Orientation int               `json:"orientation"`    // EXIF 方向标签，缺失时为 1
+	Exif        map[string]string `json:"exif,omitempty"` // EXIF 标签
+	DPI         *DPI              `json:"dpi,omitempty"`  // 分辨率，缺失时为空
+	Size        int64             `json:"size"`           // 文件大小（字节）
+}
+
+// Inspect 读取图片并返回其元信息
+func (s *DefaultImageService) Inspect(r io.Reader) (*ImageInfo, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxInspectSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取图片失败: %v", err)
+	}
+	if len(data) > maxInspectSize {
+		return nil, fmt.Errorf("%w: 图片超过 %d MB", ErrInvalidInput, maxInspectSize/(1024*1024))
+	}
+	return inspectImage(data)
+}
+
+// inspectImage 解析图片数据的元信息
+func inspectImage(data []byte) (*ImageInfo, error) {
+	img, format, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	info := &ImageInfo{
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+		Format:      format,
+		ColorModel:  colorModelName(img),
+		HasAlpha:    hasAlpha(img),
+		Orientation: 1,
+		Size:        int64(len(data)),
+	}
+
+	// TIFF 文件本身就是 EXIF 结构
+	var exif *ExifData
+	switch format {
+	case "jpeg":
+		exif, _ = ParseJPEGExif(data)
+	case "tiff":
+		exif, _ = parseExif(data)
+	}
+	if exif != nil {
+		info.Exif = exif.Tags
+		info.Orientation = exif.Orientation
+		info.DPI = exifDPI(exif)
+	}
+
+	if info.DPI == nil {
+		switch format {
+		case "jpeg":
+			info.DPI = jfifDPI(data)
+		case "png":
+			info.DPI = pngDPI(data)
+		}
+	}
+	return info, nil
+}
+
+// colorModelName 返回图片颜色模型的名称
+func colorModelName(img image.Image) string {
+	switch m := img.(type) {
+	case *image.YCbCr:
+		ratio := strings.TrimPrefix(m.SubsampleRatio.String(), "YCbCrSubsampleRatio")
+		if len(ratio) == 3 {
+			ratio = fmt.Sprintf("%c:%c:%c", ratio[0], ratio[1], ratio[2])
+		}
+		return "YCbCr " + ratio
+	case *image.Paletted:
+		return fmt.Sprintf("Paletted(%d)", len(m.Palette))
+	}
+
+	switch img.ColorModel() {
+	case color.RGBAModel:
+		return "RGBA"
+	case color.RGBA64Model:
+		return "RGBA64"
+	case color.NRGBAModel:
+		return "NRGBA"
+	case color.NRGBA64Model:
+		return "NRGBA64"
+	case color.AlphaModel:
+		return "Alpha"
+	case color.Alpha16Model:
+		return "Alpha16"
+	case color.GrayModel:
+		return "Gray"
+	case color.Gray16Model:
+		return "Gray16"
+	case color.CMYKModel:
+		return "CMYK"
+	case color.NYCbCrAModel:
+		return "NYCbCrA"
+	default:
+		return "unknown"
+	}
+}
+
+// hasAlpha 判断图片是否包含非不透明像素
+func hasAlpha(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return !o.Opaque()
+	}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseRational 解析 "n/d" 形式的有理数
+func parseRational(s string) (float64, bool) {
+	num, den, found := strings.Cut(s, "/")
+	if !found {
+		return 0, false
+	}
+	n, err1 := strconv.ParseFloat(num, 64)
+	d, err2 := strconv.ParseFloat(den, 64)
+	if err1 != nil || err2 != nil || d == 0 {
+		return 0, false
+	}
+	return n / d, true
+}
+
+// exifDPI 从 EXIF 分辨率标签计算 DPI
+func exifDPI(exif *ExifData) *DPI {
+	x, okX := parseRational(exif.Tags["XResolution"])
+	y, okY := parseRational(exif.Tags["YResolution"])
+	if !okX || !okY || x <= 0 || y <= 0 {
+		return nil
+	}
+	switch exif.Tags["ResolutionUnit"] {
+	case "1":
+		return nil // 无单位
+	case "3":
+		return &DPI{X: x * 2.54, Y: y * 2.54} // 每厘米
+	default:
+		return &DPI{X: x, Y: y} // 每英寸
+	}
+}
+
+// jfifDPI 从 JFIF APP0 段读取 DPI
+func jfifDPI(data []byte) *DPI {
+	if len(data) < 20 || data[2] != 0xFF || data[3] != 0xE0 || !bytes.Equal(data[6:11], []byte("JFIF\x00")) {
+		return nil
+	}
+	unit := data[13]
+	x := float64(binary.BigEndian.Uint16(data[14:]))
+	y := float64(binary.BigEndian.Uint16(data[16:]))
+	switch unit {
+	case 1:
+		return &DPI{X: x, Y: y}
+	case 2:
+		return &DPI{X: x * 2.54, Y: y * 2.54}
+	default:
+		return nil
+	}
+}
+
+// pngDPI 从 PNG pHYs 块读取 DPI
+func pngDPI(data []byte) *DPI {
+	pos := 8
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		chunk := string(data[pos+4 : pos+8])
+		if chunk == "pHYs" && length == 9 && pos+17 <= len(data) {
+			x := float64(binary.BigEndian.Uint32(data[pos+8:]))
+			y := float64(binary.BigEndian.Uint32(data[pos+12:]))
+			if data[pos+16] != 1 {
+				return nil
+			}
+			// 每米像素数换算为每英寸
+			return &DPI{X: x * 0.0254, Y: y * 0.0254}
+		}
+		if chunk == "IDAT" || chunk == "IEND" || length < 0 {
+			return nil
+		}
+		pos += 12 + length
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// 元数据处理模式
+const (
+	MetadataStrip         = "strip"          // 移除全部元数据
+	MetadataKeep          = "keep"           // 保留 EXIF（仅 JPEG 输出）
+	MetadataKeepCopyright = "keep-copyright" // 只保留作者与版权信息（仅 JPEG 输出）
+)
+
+// ParseMetadataMode 解析元数据处理模式，空字符串表示移除
+func ParseMetadataMode(mode string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", MetadataStrip:
+		return MetadataStrip, nil
+	case MetadataKeep:
+		return MetadataKeep, nil
+	case MetadataKeepCopyright:
+		return MetadataKeepCopyright, nil
+	default:
+		return "", fmt.Errorf("%w: 无效的元数据模式 %s", ErrInvalidInput, mode)
+	}
+}
+
+// applyOrientation 按 EXIF 方向标签旋转或翻转图片
+func applyOrientation(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	default:
+		return img
+	}
+}
+
+// pngMetadataChunks 可能携带元数据的 PNG 块
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"iCCP": true,
+	"tIME": true,
+}
+
+// containsMetadata 判断原始文件是否携带元数据
+// TIFF 本身就是 EXIF 结构，始终视为携带元数据
+func containsMetadata(data []byte, format string) bool {
+	switch format {
+	case "jpeg":
+		return jpegHasMetadata(data)
+	case "png":
+		return pngHasMetadata(data)
+	case "webp":
+		return webpHasMetadata(data)
+	case "tiff":
+		return true
+	default:
+		return false
+	}
+}
+
+// jpegHasMetadata 判断 JPEG 是否包含 APP1-APP15 或 COM 段
+func jpegHasMetadata(data []byte) bool {
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return true
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			pos++
+			continue
+		}
+		if marker == jpegMarkerSOS {
+			return false
+		}
+		if (marker >= jpegMarkerAPP1 && marker <= 0xEF) || marker == 0xFE {
+			return true
+		}
+		pos += 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+	}
+	return false
+}
+
+// pngHasMetadata 判断 PNG 是否包含元数据块
+func pngHasMetadata(data []byte) bool {
+	pos := 8
+	for pos+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		chunk := string(data[pos+4 : pos+8])
+		if pngMetadataChunks[chunk] {
+			return true
+		}
+		if chunk == "IEND" || length < 0 {
+			return false
+		}
+		pos += 12 + length
+	}
+	return false
+}
+
+// webpMetadataChunks 可能携带元数据的 WebP 块
+var webpMetadataChunks = map[string]bool{
+	"EXIF": true,
+	"XMP ": true,
+	"ICCP": true,
+}
+
+// webpHasMetadata 判断 WebP 是否包含元数据块，结构无法解析或 RIFF 之后还有数据时视为包含
+func webpHasMetadata(data []byte) bool {
+	if len(data) < 12 {
+		return true
+	}
+	end := 8 + int(binary.LittleEndian.Uint32(data[4:8]))
+	if end != len(data) {
+		return true
+	}
+	pos := 12
+	for pos+8 <= end {
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		if size < 0 || pos+8+size > end {
+			return true
+		}
+		if webpMetadataChunks[string(data[pos:pos+4])] {
+			return true
+		}
+		pos += 8 + size + size&1
+	}
+	return false
+}
+
+// metadataPayload 按模式生成要写入输出的 EXIF 数据，只支持 JPEG 输出
+func metadataPayload(exif *ExifData, outputFormat, mode string) []byte {
+	if exif == nil || outputFormat != "jpeg" {
+		return nil
+	}
+	switch mode {
+	case MetadataKeep:
+		return exif.withNormalOrientation()
+	case MetadataKeepCopyright:
+		return exif.copyrightOnly()
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,231 @@
+package models
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testExifTag 测试用 EXIF 标签
+type testExifTag struct {
+	tag   uint16
+	short uint16 // typ 为 SHORT 时的值
+	ascii string // typ 为 ASCII 时的值
+}
+
+// buildTestExif 生成只有一个 IFD 的小端 TIFF 数据，字符串值存放在 IFD 之后
+func buildTestExif(tags []testExifTag) []byte {
+	order := binary.LittleEndian
+	ifdSize := 2 + len(tags)*12 + 4
+	ifd := make([]byte, ifdSize)
+	order.PutUint16(ifd, uint16(len(tags)))
+	var values []byte
+	for i, tag := range tags {
+		entry := ifd[2+i*12:]
+		order.PutUint16(entry[0:], tag.tag)
+		if tag.ascii == "" {
+			order.PutUint16(entry[2:], exifTypeShort)
+			order.PutUint32(entry[4:], 1)
+			order.PutUint16(entry[8:], tag.short)
+			continue
+		}
+		value := append([]byte(tag.ascii), 0)
+		order.PutUint16(entry[2:], exifTypeASCII)
+		order.PutUint32(entry[4:], uint32(len(value)))
+		order.PutUint32(entry[8:], uint32(8+ifdSize+len(values)))
+		values = append(values, value...)
+	}
+	out := append([]byte{'I', 'I', 42, 0, 8, 0, 0, 0}, ifd...)
+	return append(out, values...)
+}
+
+// newTestJPEGWithExif 生成带 EXIF 的 JPEG
+func newTestJPEGWithExif(t *testing.T, img image.Image, tags []testExifTag) []byte {
+	t.Helper()
+	data, err := insertJPEGExif(encodeTestImage(t, img, "jpeg"), buildTestExif(tags))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseMetadataMode(t *testing.T) {
+	tests := []struct {
+		mode, want string
+		valid      bool
+	}{
+		{"", MetadataStrip, true},
+		{"strip", MetadataStrip, true},
+		{" KEEP ", MetadataKeep, true},
+		{"keep-copyright", MetadataKeepCopyright, true},
+		{"all", "", false},
+	}
+	for _, tt := range tests {
+		got, err := ParseMetadataMode(tt.mode)
+		if (err == nil) != tt.valid || got != tt.want {
+			t.Errorf("ParseMetadataMode(%q) = %q, %v, want %q", tt.mode, got, err, tt.want)
+		}
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// 2x3 的图片，左上角像素为红色，校正后检查红色像素的位置与尺寸
+	red := color.NRGBA{R: 255, A: 255}
+	img := newUniformImage(2, 3, color.NRGBA{A: 255})
+	img.SetNRGBA(0, 0, red)
+
+	tests := []struct {
+		orientation int
+		size        image.Point
+		red         image.Point
+	}{
+		{1, image.Pt(2, 3), image.Pt(0, 0)},
+		{2, image.Pt(2, 3), image.Pt(1, 0)},
+		{3, image.Pt(2, 3), image.Pt(1, 2)},
+		{4, image.Pt(2, 3), image.Pt(0, 2)},
+		{5, image.Pt(3, 2), image.Pt(0, 0)},
+		{6, image.Pt(3, 2), image.Pt(2, 0)},
+		{7, image.Pt(3, 2), image.Pt(2, 1)},
+		{8, image.Pt(3, 2), image.Pt(0, 1)},
+		{9, image.Pt(2, 3), image.Pt(0, 0)},
+	}
+	for _, tt := range tests {
+		got := applyOrientation(img, tt.orientation)
+		if size := got.Bounds().Size(); size != tt.size {
+			t.Errorf("方向 %d: 尺寸 = %v, want %v", tt.orientation, size, tt.size)
+			continue
+		}
+		if c := color.NRGBAModel.Convert(got.At(tt.red.X, tt.red.Y)); c != red {
+			t.Errorf("方向 %d: 像素 %v = %v, want 红色", tt.orientation, tt.red, c)
+		}
+	}
+}
+
+func TestDecodeOrientedJPEG(t *testing.T) {
+	data := newTestJPEGWithExif(t, newBlockImage(16, 8, 1), []testExifTag{{tag: tagOrientation, short: 6}})
+	img, format, exif, err := decodeOriented(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" || exif == nil || exif.Orientation != 6 {
+		t.Fatalf("decodeOriented = %s, %+v", format, exif)
+	}
+	if size := img.Bounds().Size(); size != image.Pt(8, 16) {
+		t.Errorf("校正后尺寸 = %v, want (8,16)", size)
+	}
+}
+
+func TestMetadataPayload(t *testing.T) {
+	data := newTestJPEGWithExif(t, newBlockImage(8, 8, 1), []testExifTag{
+		{tag: tagOrientation, short: 6},
+		{tag: 0x010F, ascii: "Camera"},
+		{tag: tagArtist, ascii: "Alice"},
+		{tag: tagCopyright, ascii: "(c) 2024 Alice"},
+	})
+	exif, err := ParseJPEGExif(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, format, mode string
+		want               map[string]string // 为空表示不写入元数据
+		orientation        int
+	}{
+		{"移除", "jpeg", MetadataStrip, nil, 0},
+		{"非 JPEG 输出", "png", MetadataKeep, nil, 0},
+		{"保留全部并重置方向", "jpeg", MetadataKeep, map[string]string{
+			"Make": "Camera", "Artist": "Alice", "Copyright": "(c) 2024 Alice", "Orientation": "1",
+		}, 1},
+		{"只保留版权", "jpeg", MetadataKeepCopyright, map[string]string{
+			"Artist": "Alice", "Copyright": "(c) 2024 Alice",
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := metadataPayload(exif, tt.format, tt.mode)
+			if tt.want == nil {
+				if payload != nil {
+					t.Errorf("metadataPayload 应为空，得到 %d 字节", len(payload))
+				}
+				return
+			}
+			parsed, err := parseExif(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(parsed.Tags) != len(tt.want) || parsed.Orientation != tt.orientation {
+				t.Errorf("标签 = %v，方向 %d, want %v，方向 %d", parsed.Tags, parsed.Orientation, tt.want, tt.orientation)
+			}
+			for name, value := range tt.want {
+				if parsed.Tags[name] != value {
+					t.Errorf("%s = %q, want %q", name, parsed.Tags[name], value)
+				}
+			}
+		})
+	}
+
+	if payload := metadataPayload(nil, "jpeg", MetadataKeep); payload != nil {
+		t.Error("没有 EXIF 时不应写入元数据")
+	}
+}
+
+// buildTestWebP 按块生成 WebP 容器，trailing 为 RIFF 之后的额外数据
+func buildTestWebP(chunks map[string][]byte, order []string, trailing []byte) []byte {
+	body := []byte("WEBP")
+	for _, name := range order {
+		payload := chunks[name]
+		header := make([]byte, 8)
+		copy(header, name)
+		binary.LittleEndian.PutUint32(header[4:], uint32(len(payload)))
+		body = append(body, header...)
+		body = append(body, payload...)
+		if len(payload)%2 == 1 {
+			body = append(body, 0)
+		}
+	}
+	out := []byte("RIFF")
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(body)))
+	out = append(out, body...)
+	return append(out, trailing...)
+}
+
+func TestContainsMetadata(t *testing.T) {
+	plainJPEG := encodeTestImage(t, newBlockImage(8, 8, 1), "jpeg")
+	exifJPEG := newTestJPEGWithExif(t, newBlockImage(8, 8, 1), []testExifTag{{tag: tagOrientation, short: 1}})
+	plainPNG := encodeTestImage(t, newBlockImage(8, 8, 1), "png")
+	chunks := map[string][]byte{
+		"VP8 ": []byte("odd"),
+		"EXIF": []byte("II*\x00"),
+		"XMP ": []byte("<x/>"),
+		"ICCP": []byte("icc"),
+	}
+	truncated := buildTestWebP(chunks, []string{"VP8 "}, nil)
+	binary.LittleEndian.PutUint32(truncated[16:], 100)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+		want   bool
+	}{
+		{"JPEG 无元数据", plainJPEG, "jpeg", false},
+		{"JPEG EXIF", exifJPEG, "jpeg", true},
+		{"PNG 无元数据", plainPNG, "png", false},
+		{"TIFF", []byte("II*\x00"), "tiff", true},
+		{"GIF", []byte("GIF89a"), "gif", false},
+		{"WebP 无元数据", buildTestWebP(chunks, []string{"VP8 "}, nil), "webp", false},
+		{"WebP EXIF", buildTestWebP(chunks, []string{"VP8 ", "EXIF"}, nil), "webp", true},
+		{"WebP XMP", buildTestWebP(chunks, []string{"XMP ", "VP8 "}, nil), "webp", true},
+		{"WebP ICC", buildTestWebP(chunks, []string{"ICCP", "VP8 "}, nil), "webp", true},
+		{"WebP 尾部数据", buildTestWebP(chunks, []string{"VP8 "}, []byte("extra")), "webp", true},
+		{"WebP 块长度越界", truncated, "webp", true},
+		{"WebP 过短", []byte("RIFF"), "webp", true},
+	}
+	for _, tt := range tests {
+		if got := containsMetadata(tt.data, tt.format); got != tt.want {
+			t.Errorf("%s: containsMetadata = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
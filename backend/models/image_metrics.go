@@ -0,0 +1,208 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	maxPSNR        = 100 // 两张图片完全相同时记录的 PSNR (dB)
+	ssimWindow     = 8   // SSIM 窗口边长
+	ssimWindowStep = 4   // SSIM 窗口滑动步长
+)
+
+// SSIM 公式中的稳定常数，L = 255
+const (
+	ssimC1 = (0.01 * 255) * (0.01 * 255)
+	ssimC2 = (0.03 * 255) * (0.03 * 255)
+)
+
+// QualityMetrics 压缩结果相对编码前图片的客观画质指标
+type QualityMetrics struct {
+	PSNR     float64      `json:"psnr"`     // 峰值信噪比 (dB)，完全相同时为 100
+	SSIM     float64      `json:"ssim"`     // 亮度通道的结构相似性 (0-1)
+	MaxError ChannelError `json:"maxError"` // 各通道的最大绝对误差 (0-255)
+}
+
+// ChannelError 各通道的误差
+type ChannelError struct {
+	R int `json:"r"`
+	G int `json:"g"`
+	B int `json:"b"`
+	A int `json:"a"`
+}
+
+// ValidateMinSSIM 校验最低 SSIM，0 表示不限制
+func ValidateMinSSIM(minSSIM float64) error {
+	if minSSIM < 0 || minSSIM > 1 || math.IsNaN(minSSIM) {
+		return fmt.Errorf("%w: 最低 SSIM 必须在 0-1 之间", ErrInvalidInput)
+	}
+	return nil
+}
+
+// measureQuality 解码编码结果并与编码前的图片比较
+// 目标大小模式缩小了尺寸时，参考图片先缩放到输出尺寸
+// 编码结果由服务端生成，解码时不套用上传文件的尺寸限制
+func measureQuality(reference image.Image, encoded []byte) (*QualityMetrics, error) {
+	decoded, _, err := image.Decode(bytes.NewReader(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("解码压缩结果失败: %v", err)
+	}
+	bounds := decoded.Bounds()
+	if reference.Bounds().Size() != bounds.Size() {
+		reference = imaging.Resize(reference, bounds.Dx(), bounds.Dy(), imaging.Lanczos)
+	}
+	return compareImages(imaging.Clone(reference), imaging.Clone(decoded)), nil
+}
+
+// compareImages 计算两张相同尺寸图片的画质指标
+// PSNR 与 SSIM 按白色背景合成透明像素后计算
+func compareImages(a, b *image.NRGBA) *QualityMetrics {
+	width, height := a.Bounds().Dx(), a.Bounds().Dy()
+	lumaA := make([]float64, width*height)
+	lumaB := make([]float64, width*height)
+
+	var sum float64
+	var maxErr ChannelError
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i, j := a.PixOffset(x, y), b.PixOffset(x, y)
+			pa, pb := a.Pix[i:i+4:i+4], b.Pix[j:j+4:j+4]
+			ra, ga, ba := overWhite(pa)
+			rb, gb, bb := overWhite(pb)
+
+			dr, dg, db := ra-rb, ga-gb, ba-bb
+			sum += dr*dr + dg*dg + db*db
+			maxErr.R = max(maxErr.R, int(math.Round(math.Abs(dr))))
+			maxErr.G = max(maxErr.G, int(math.Round(math.Abs(dg))))
+			maxErr.B = max(maxErr.B, int(math.Round(math.Abs(db))))
+			maxErr.A = max(maxErr.A, absInt(int(pa[3])-int(pb[3])))
+
+			lumaA[y*width+x] = 0.299*ra + 0.587*ga + 0.114*ba
+			lumaB[y*width+x] = 0.299*rb + 0.587*gb + 0.114*bb
+		}
+	}
+
+	psnr := float64(maxPSNR)
+	if mse := sum / float64(3*width*height); mse > 0 {
+		psnr = math.Min(10*math.Log10(255*255/mse), maxPSNR)
+	}
+	return &QualityMetrics{
+		PSNR:     math.Round(psnr*100) / 100,
+		SSIM:     math.Round(meanSSIM(lumaA, lumaB, width, height)*10000) / 10000,
+		MaxError: maxErr,
+	}
+}
+
+// overWhite 将 NRGBA 像素合成到白色背景上
+func overWhite(p []uint8) (r, g, b float64) {
+	alpha := float64(p[3]) / 255
+	return float64(p[0])*alpha + 255*(1-alpha),
+		float64(p[1])*alpha + 255*(1-alpha),
+		float64(p[2])*alpha + 255*(1-alpha)
+}
+
+// meanSSIM 以 8x8 窗口、步长 4 计算平均 SSIM，图片小于窗口时整张图片作为一个窗口
+func meanSSIM(a, b []float64, width, height int) float64 {
+	windowW, windowH := min(ssimWindow, width), min(ssimWindow, height)
+	var total float64
+	windows := 0
+	for y := 0; ; y += ssimWindowStep {
+		y = min(y, height-windowH)
+		for x := 0; ; x += ssimWindowStep {
+			x = min(x, width-windowW)
+			total += windowSSIM(a, b, width, x, y, windowW, windowH)
+			windows++
+			if x+windowW >= width {
+				break
+			}
+		}
+		if y+windowH >= height {
+			break
+		}
+	}
+	return total / float64(windows)
+}
+
+// windowSSIM 计算单个窗口的 SSIM
+func windowSSIM(a, b []float64, stride, x0, y0, w, h int) float64 {
+	n := float64(w * h)
+	var sumA, sumB, sumAA, sumBB, sumAB float64
+	for y := y0; y < y0+h; y++ {
+		for x := x0; x < x0+w; x++ {
+			va, vb := a[y*stride+x], b[y*stride+x]
+			sumA += va
+			sumB += vb
+			sumAA += va * va
+			sumBB += vb * vb
+			sumAB += va * vb
+		}
+	}
+	meanA, meanB := sumA/n, sumB/n
+	varA := sumAA/n - meanA*meanA
+	varB := sumBB/n - meanB*meanB
+	covar := sumAB/n - meanA*meanB
+	return ((2*meanA*meanB + ssimC1) * (2*covar + ssimC2)) /
+		((meanA*meanA + meanB*meanB + ssimC1) * (varA + varB + ssimC2))
+}
+
+// ensureMinSSIM 编码结果低于最低 SSIM 时提高 JPEG 质量重新编码
+// 在当前质量与 100 之间二分查找满足要求的最低质量；非 JPEG 输出或设置了目标大小时直接拒绝
+func ensureMinSSIM(ctx context.Context, img, reference image.Image, format string, options CompressionOption, encoded *encodedImage, metrics *QualityMetrics) (*encodedImage, *QualityMetrics, error) {
+	if options.MinSSIM <= 0 || metrics.SSIM >= options.MinSSIM {
+		return encoded, metrics, nil
+	}
+	belowThreshold := func(ssim float64) error {
+		return fmt.Errorf("%w: SSIM %.4f 低于 %.4f", ErrQualityBelowThreshold, ssim, options.MinSSIM)
+	}
+	if format != "jpeg" || options.TargetSize > 0 {
+		return nil, nil, belowThreshold(metrics.SSIM)
+	}
+
+	iterations := encoded.Iterations
+	var best *encodedImage
+	var bestMetrics *QualityMetrics
+	highest := metrics.SSIM
+	low, high := encoded.Quality+1, 100
+	for low <= high {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		mid := (low + high) / 2
+		data, err := encodeToBytes(img, format, encodeParams{Quality: mid})
+		iterations++
+		if err != nil {
+			return nil, nil, err
+		}
+		m, err := measureQuality(reference, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		highest = math.Max(highest, m.SSIM)
+		if m.SSIM >= options.MinSSIM {
+			best = &encodedImage{Data: data, Quality: mid, Width: encoded.Width, Height: encoded.Height, Image: encoded.Image}
+			bestMetrics = m
+			high = mid - 1
+		} else {
+			low = mid + 1
+		}
+	}
+	if best == nil {
+		return nil, nil, belowThreshold(highest)
+	}
+	best.Iterations = iterations
+	return best, bestMetrics, nil
+}
+
+// absInt 返回整数的绝对值
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// shiftImage 将图片每个颜色通道加上 delta
+func shiftImage(img *image.NRGBA, delta int) *image.NRGBA {
+	out := imaging.Clone(img)
+	for i := 0; i < len(out.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			out.Pix[i+c] = uint8(max(0, min(255, int(out.Pix[i+c])+delta)))
+		}
+	}
+	return out
+}
+
+func TestCompareImages(t *testing.T) {
+	base := newBlockImage(32, 32, 1)
+	for i := 0; i < len(base.Pix); i += 4 {
+		// 避免加减后溢出，使 PSNR 可以精确计算
+		for c := 0; c < 3; c++ {
+			base.Pix[i+c] = 20 + base.Pix[i+c]/2
+		}
+	}
+	transparent := newUniformImage(16, 16, color.NRGBA{R: 10, G: 200, B: 30, A: 0})
+	white := newUniformImage(16, 16, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+	tests := []struct {
+		name     string
+		a, b     *image.NRGBA
+		psnr     float64
+		ssim     float64 // 为负数时只要求小于 1
+		maxError ChannelError
+	}{
+		{"相同图片", base, base, maxPSNR, 1, ChannelError{}},
+		{"各通道偏移 10", base, shiftImage(base, 10), 28.13, -1, ChannelError{R: 10, G: 10, B: 10}},
+		{"各通道偏移 1", base, shiftImage(base, -1), 48.13, -1, ChannelError{R: 1, G: 1, B: 1}},
+		{"透明像素按白色合成", transparent, white, maxPSNR, 1, ChannelError{A: 255}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareImages(tt.a, tt.b)
+			if math.Abs(got.PSNR-tt.psnr) > 0.01 {
+				t.Errorf("PSNR = %v, want %v", got.PSNR, tt.psnr)
+			}
+			if tt.ssim >= 0 && got.SSIM != tt.ssim {
+				t.Errorf("SSIM = %v, want %v", got.SSIM, tt.ssim)
+			}
+			if tt.ssim < 0 && (got.SSIM >= 1 || got.SSIM <= 0) {
+				t.Errorf("SSIM = %v, want (0, 1)", got.SSIM)
+			}
+			if got.MaxError != tt.maxError {
+				t.Errorf("MaxError = %+v, want %+v", got.MaxError, tt.maxError)
+			}
+		})
+	}
+}
+
+func TestMeanSSIMOrdering(t *testing.T) {
+	// 误差越大 SSIM 越低
+	base := newBlockImage(64, 64, 2)
+	var last float64 = 2
+	for _, delta := range []int{0, 4, 16, 64} {
+		noisy := imaging.Clone(base)
+		for i := 0; i < len(noisy.Pix); i += 4 {
+			if (i/4)%2 == 0 {
+				for c := 0; c < 3; c++ {
+					noisy.Pix[i+c] = uint8(max(0, min(255, int(noisy.Pix[i+c])+delta)))
+				}
+			}
+		}
+		ssim := compareImages(base, noisy).SSIM
+		if ssim >= last {
+			t.Errorf("误差 %d 的 SSIM = %v，应低于更小误差的 %v", delta, ssim, last)
+		}
+		last = ssim
+	}
+}
+
+func TestMeasureQuality(t *testing.T) {
+	reference := newBlockImage(64, 48, 3)
+
+	t.Run("无损编码", func(t *testing.T) {
+		metrics, err := measureQuality(reference, encodeTestImage(t, reference, "png"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if metrics.PSNR != maxPSNR || metrics.SSIM != 1 {
+			t.Errorf("measureQuality = %+v, want PSNR %d SSIM 1", metrics, maxPSNR)
+		}
+	})
+
+	t.Run("有损编码", func(t *testing.T) {
+		metrics, err := measureQuality(reference, encodeTestImage(t, reference, "jpeg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if metrics.PSNR >= maxPSNR || metrics.PSNR < 20 || metrics.SSIM >= 1 || metrics.SSIM < 0.5 {
+			t.Errorf("measureQuality = %+v, want 20 <= PSNR < %d, 0.5 <= SSIM < 1", metrics, maxPSNR)
+		}
+	})
+
+	t.Run("输出尺寸较小时缩放参考图片", func(t *testing.T) {
+		small := imaging.Resize(reference, 32, 24, imaging.Lanczos)
+		metrics, err := measureQuality(reference, encodeTestImage(t, small, "png"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if metrics.PSNR != maxPSNR {
+			t.Errorf("PSNR = %v, want %d", metrics.PSNR, maxPSNR)
+		}
+	})
+
+	t.Run("无法解码", func(t *testing.T) {
+		if _, err := measureQuality(reference, []byte("not an image")); err == nil {
+			t.Error("measureQuality 应返回错误")
+		}
+	})
+}
+
+func TestValidateMinSSIM(t *testing.T) {
+	tests := []struct {
+		value float64
+		valid bool
+	}{
+		{0, true},
+		{0.95, true},
+		{1, true},
+		{-0.1, false},
+		{1.01, false},
+		{math.NaN(), false},
+	}
+	for _, tt := range tests {
+		if err := ValidateMinSSIM(tt.value); (err == nil) != tt.valid {
+			t.Errorf("ValidateMinSSIM(%v) = %v, want valid %v", tt.value, err, tt.valid)
+		}
+	}
+}
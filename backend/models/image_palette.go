@@ -0,0 +1,182 @@
+package models
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	DefaultPaletteSize = 5   // 默认提取的主色数量
+	maxPaletteSize     = 16  // 最多提取的主色数量
+	paletteSampleSide  = 128 // 提取主色前缩小到的最大宽高
+	paletteIterations  = 10  // k-means 最多迭代次数
+	paletteMinAlpha    = 128 // 不透明度低于该值的像素不参与统计
+)
+
+// RGBColor RGB 颜色
+type RGBColor struct {
+	R uint8 `json:"r"`
+	G uint8 `json:"g"`
+	B uint8 `json:"b"`
+}
+
+// HSLColor HSL 颜色，h 为角度 (0-360)，s、l 为百分比 (0-100)
+type HSLColor struct {
+	H float64 `json:"h"`
+	S float64 `json:"s"`
+	L float64 `json:"l"`
+}
+
+// ColorValue 同一颜色的 HEX、RGB 与 HSL 表示
+type ColorValue struct {
+	Hex string   `json:"hex"`
+	RGB RGBColor `json:"rgb"`
+	HSL HSLColor `json:"hsl"`
+}
+
+// PaletteColor 主色及其像素占比
+type PaletteColor struct {
+	ColorValue
+	Share float64 `json:"share"` // 占参与统计像素的比例 (0-1)
+}
+
+// ColorPalette 图片的主色与平均色
+type ColorPalette struct {
+	Colors  []PaletteColor `json:"colors"`  // 按占比从高到低排序
+	Average ColorValue     `json:"average"` // 平均色
+}
+
+// ValidatePaletteSize 校验主色数量
+func ValidatePaletteSize(size int) error {
+	if size < 1 || size > maxPaletteSize {
+		return fmt.Errorf("%w: 主色数量必须在 1-%d 之间", ErrInvalidInput, maxPaletteSize)
+	}
+	return nil
+}
+
+// NewColorValue 根据 RGB 生成颜色的各种表示
+func NewColorValue(r, g, b uint8) ColorValue {
+	h, s, l := rgbToHSL(r, g, b)
+	return ColorValue{
+		Hex: fmt.Sprintf("#%02x%02x%02x", r, g, b),
+		RGB: RGBColor{R: r, G: g, B: b},
+		HSL: HSLColor{
+			H: math.Round(h*10) / 10,
+			S: math.Round(s*1000) / 10,
+			L: math.Round(l*1000) / 10,
+		},
+	}
+}
+
+// ExtractPalette 解码图片并提取主色
+func (s *DefaultImageService) ExtractPalette(data []byte, size int) (*ColorPalette, error) {
+	if err := ValidatePaletteSize(size); err != nil {
+		return nil, err
+	}
+	img, _, _, err := decodeOriented(data)
+	if err != nil {
+		return nil, err
+	}
+	return extractPalette(img, size), nil
+}
+
+// extractPalette 在缩小后的图片上以中位切分结果为初始中心执行 k-means，提取主色与平均色
+// 透明像素不参与统计，完全透明的图片按全部像素统计
+func extractPalette(img image.Image, size int) *ColorPalette {
+	small := imaging.Fit(img, paletteSampleSide, paletteSampleSide, imaging.Box)
+	pixels := make([]color.NRGBA, 0, len(small.Pix)/4)
+	for i := 0; i < len(small.Pix); i += 4 {
+		if small.Pix[i+3] >= paletteMinAlpha {
+			pixels = append(pixels, color.NRGBA{R: small.Pix[i], G: small.Pix[i+1], B: small.Pix[i+2], A: 0xff})
+		}
+	}
+	if len(pixels) == 0 {
+		for i := 0; i < len(small.Pix); i += 4 {
+			pixels = append(pixels, color.NRGBA{R: small.Pix[i], G: small.Pix[i+1], B: small.Pix[i+2], A: 0xff})
+		}
+	}
+
+	var sum [3]float64
+	for _, p := range pixels {
+		sum[0] += float64(p.R)
+		sum[1] += float64(p.G)
+		sum[2] += float64(p.B)
+	}
+	n := float64(len(pixels))
+	palette := &ColorPalette{
+		Average: NewColorValue(clampChannel(sum[0]/n), clampChannel(sum[1]/n), clampChannel(sum[2]/n)),
+	}
+
+	// 中位切分会重新排序像素，使用副本
+	seeds := medianCut(append([]color.NRGBA(nil), pixels...), size)
+	centers := make([][3]float64, len(seeds))
+	for i, box := range seeds {
+		c := box.average()
+		centers[i] = [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+	}
+	counts := kMeans(pixels, centers)
+
+	for i, center := range centers {
+		if counts[i] == 0 {
+			continue
+		}
+		palette.Colors = append(palette.Colors, PaletteColor{
+			ColorValue: NewColorValue(clampChannel(center[0]), clampChannel(center[1]), clampChannel(center[2])),
+			Share:      math.Round(float64(counts[i])/n*10000) / 10000,
+		})
+	}
+	sort.SliceStable(palette.Colors, func(i, j int) bool {
+		return palette.Colors[i].Share > palette.Colors[j].Share
+	})
+	return palette
+}
+
+// kMeans 迭代更新聚类中心直到分配不再变化，返回每个中心的像素数
+func kMeans(pixels []color.NRGBA, centers [][3]float64) []int {
+	assignments := make([]int, len(pixels))
+	for i := range assignments {
+		assignments[i] = -1
+	}
+	counts := make([]int, len(centers))
+	for iteration := 0; iteration < paletteIterations; iteration++ {
+		changed := false
+		sums := make([][3]float64, len(centers))
+		for i := range counts {
+			counts[i] = 0
+		}
+		for i, p := range pixels {
+			v := [3]float64{float64(p.R), float64(p.G), float64(p.B)}
+			nearest, best := 0, math.MaxFloat64
+			for j, center := range centers {
+				dr, dg, db := v[0]-center[0], v[1]-center[1], v[2]-center[2]
+				if d := dr*dr + dg*dg + db*db; d < best {
+					nearest, best = j, d
+				}
+			}
+			if assignments[i] != nearest {
+				assignments[i] = nearest
+				changed = true
+			}
+			counts[nearest]++
+			for c := 0; c < 3; c++ {
+				sums[nearest][c] += v[c]
+			}
+		}
+		for j := range centers {
+			if counts[j] > 0 {
+				for c := 0; c < 3; c++ {
+					centers[j][c] = sums[j][c] / float64(counts[j])
+				}
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	return counts
+}
@@ -0,0 +1,247 @@
+package models
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// polyglotMarkers 出现在图片中即视为混合文件的内容（小写，均以 '<' 开头）
+var polyglotMarkers = [][]byte{
+	[]byte("<?php"),
+	[]byte("<script"),
+	[]byte("<html"),
+	[]byte("<!doctype html"),
+	[]byte("<svg"),
+}
+
+// isPolyglot 判断图片中是否混入 ZIP 结构或网页、脚本内容
+// 只检查结束标记之后的尾部数据与元数据、注释块，像素数据中偶然出现的字节序列不会造成误判
+func isPolyglot(format string, data []byte) bool {
+	// ZIP 的目录结束标记位于文件末尾，GIFAR 等混合文件依赖它被识别为压缩包
+	tail := data
+	if len(tail) > zipTailScanSize {
+		tail = tail[len(tail)-zipTailScanSize:]
+	}
+	if bytes.Contains(tail, []byte("PK\x05\x06")) {
+		return true
+	}
+
+	for _, region := range scanRegions(format, data) {
+		for _, marker := range polyglotMarkers {
+			if containsFold(region, marker) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scanRegions 返回需要检查的尾部数据与元数据块，无法解析结构的格式检查整个文件
+func scanRegions(format string, data []byte) [][]byte {
+	var regions [][]byte
+	var ok bool
+	switch format {
+	case "jpeg":
+		regions, ok = jpegRegions(data)
+	case "png":
+		regions, ok = pngRegions(data)
+	case "gif":
+		regions, ok = gifRegions(data)
+	case "webp":
+		regions, ok = webpRegions(data)
+	case "bmp":
+		regions, ok = bmpRegions(data)
+	}
+	if !ok {
+		return [][]byte{data}
+	}
+	return regions
+}
+
+// jpegRegions 返回 APPn、COM 段与 EOI 之后的数据
+func jpegRegions(data []byte) ([][]byte, bool) {
+	var regions [][]byte
+	pos := 2 // 跳过 SOI
+	for pos+2 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, false
+		}
+		marker := data[pos+1]
+		switch {
+		case marker == 0xFF:
+			pos++ // 填充字节
+			continue
+		case marker == 0xD9:
+			return append(regions, data[pos+2:]), true
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			pos += 2
+			continue
+		}
+		if pos+4 > len(data) {
+			return nil, false
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, false
+		}
+		if (marker >= 0xE0 && marker <= 0xEF) || marker == 0xFE {
+			regions = append(regions, data[pos+4:end])
+		}
+		pos = end
+		if marker == 0xDA {
+			// 熵编码数据中的 0xFF 后跟 0x00 或 RSTn，遇到其他标记时结束
+			for pos+1 < len(data) && !(data[pos] == 0xFF && data[pos+1] != 0x00 && (data[pos+1] < 0xD0 || data[pos+1] > 0xD7)) {
+				pos++
+			}
+		}
+	}
+	return nil, false
+}
+
+// pngRegions 返回文本、EXIF 块与 IEND 之后的数据
+func pngRegions(data []byte) ([][]byte, bool) {
+	var regions [][]byte
+	pos := 8 // 跳过文件头
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		chunkType := string(data[pos+4 : pos+8])
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, false
+		}
+		switch chunkType {
+		case "IEND":
+			return append(regions, data[end:]), true
+		case "tEXt", "zTXt", "iTXt", "eXIf":
+			regions = append(regions, data[pos+8:end-4])
+		}
+		pos = end
+	}
+	return nil, false
+}
+
+// gifRegions 返回注释、应用扩展块与结束符之后的数据
+func gifRegions(data []byte) ([][]byte, bool) {
+	if len(data) < 13 {
+		return nil, false
+	}
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+	var regions [][]byte
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x3B:
+			return append(regions, data[pos+1:]), true
+		case 0x21:
+			if pos+2 > len(data) {
+				return nil, false
+			}
+			label := data[pos+1]
+			start := pos + 2
+			end, ok := skipGIFSubBlocks(data, start)
+			if !ok {
+				return nil, false
+			}
+			if label == 0xFE || label == 0xFF {
+				regions = append(regions, data[start:end])
+			}
+			pos = end
+		case 0x2C:
+			if pos+10 > len(data) {
+				return nil, false
+			}
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			end, ok := skipGIFSubBlocks(data, pos+1) // 跳过 LZW 最小码长
+			if !ok {
+				return nil, false
+			}
+			pos = end
+		default:
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// skipGIFSubBlocks 跳过以长度为 0 的块结束的数据子块，返回结束后的位置
+func skipGIFSubBlocks(data []byte, pos int) (int, bool) {
+	for pos < len(data) {
+		size := int(data[pos])
+		pos++
+		if size == 0 {
+			return pos, true
+		}
+		pos += size
+	}
+	return 0, false
+}
+
+// webpRegions 返回 EXIF、XMP 块与 RIFF 结束之后的数据
+func webpRegions(data []byte) ([][]byte, bool) {
+	end := 8 + int(binary.LittleEndian.Uint32(data[4:8]))
+	if end > len(data) || end < 12 {
+		return nil, false
+	}
+	regions := [][]byte{data[end:]}
+	pos := 12
+	for pos+8 <= end {
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		next := pos + 8 + size + size&1
+		if size < 0 || pos+8+size > end {
+			return nil, false
+		}
+		switch string(data[pos : pos+4]) {
+		case "EXIF", "XMP ":
+			regions = append(regions, data[pos+8:pos+8+size])
+		}
+		pos = next
+	}
+	return regions, true
+}
+
+// bmpRegions 返回文件头中声明的大小之后的数据
+func bmpRegions(data []byte) ([][]byte, bool) {
+	size := int(binary.LittleEndian.Uint32(data[2:6]))
+	if size < 26 || size > len(data) {
+		return nil, false
+	}
+	return [][]byte{data[size:]}, true
+}
+
+// containsFold 不复制数据，按 ASCII 忽略大小写查找小写的 marker
+func containsFold(data, marker []byte) bool {
+	for i := 0; ; {
+		j := bytes.IndexByte(data[i:], marker[0])
+		if j < 0 {
+			return false
+		}
+		i += j
+		if len(data)-i < len(marker) {
+			return false
+		}
+		if equalFoldASCII(data[i:i+len(marker)], marker) {
+			return true
+		}
+		i++
+	}
+}
+
+// equalFoldASCII 判断 b 是否与小写的 lower 在忽略 ASCII 大小写时相同
+func equalFoldASCII(b, lower []byte) bool {
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		if c != lower[i] {
+			return false
+		}
+	}
+	return true
+}
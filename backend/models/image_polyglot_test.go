@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+// withPNGChunk 在 IEND 之前插入一个块
+func withPNGChunk(data []byte, chunkType string, payload []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	chunk = append(chunk, chunkType...)
+	chunk = append(chunk, payload...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	iend := len(data) - 12
+	out := append([]byte(nil), data[:iend]...)
+	out = append(out, chunk...)
+	return append(out, data[iend:]...)
+}
+
+// withJPEGComment 在 SOI 之后插入 COM 段
+func withJPEGComment(data []byte, comment string) []byte {
+	segment := []byte{0xFF, 0xFE}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(comment)+2))
+	segment = append(segment, comment...)
+	out := append([]byte(nil), data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestIsPolyglot(t *testing.T) {
+	img := newBlockImage(16, 16, 5)
+	pngData := encodeTestImage(t, img, "png")
+	jpegData := encodeTestImage(t, img, "jpeg")
+	gifData := encodeTestImage(t, img, "gif")
+	appended := func(data []byte, tail string) []byte {
+		return append(append([]byte(nil), data...), tail...)
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		data   []byte
+		want   bool
+	}{
+		{"普通 PNG", "png", pngData, false},
+		{"普通 JPEG", "jpeg", jpegData, false},
+		{"普通 GIF", "gif", gifData, false},
+		{"末尾附加 ZIP", "png", appended(pngData, "PK\x05\x06"+string(make([]byte, 18))), true},
+		{"IEND 之后的 PHP", "png", appended(pngData, "<?php echo 1; ?>"), true},
+		{"tEXt 中的脚本", "png", withPNGChunk(pngData, "tEXt", []byte("Comment\x00<SCRIPT>alert(1)</SCRIPT>")), true},
+		{"私有块中的内容不检查", "png", withPNGChunk(pngData, "prVt", []byte("<script>")), false},
+		{"JPEG 注释中的 HTML", "jpeg", withJPEGComment(jpegData, "<HTML><body>"), true},
+		{"JPEG 普通注释", "jpeg", withJPEGComment(jpegData, "created by test"), false},
+		{"GIF 结束符之后的 SVG", "gif", appended(gifData, "<svg onload=alert(1)>"), true},
+		{"无法解析结构时检查整个文件", "tiff", []byte("II*\x00 ... <!DOCTYPE html>"), true},
+		{"无法解析结构且无标记", "tiff", []byte("II*\x00 plain"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isPolyglot(tt.format, tt.data); got != tt.want {
+				t.Errorf("isPolyglot = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package models
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"sort"
+	"strings"
+)
+
+const (
+	minPaletteColors  = 2
+	maxPaletteColors  = 256
+	maxQuantizeSample = 256 * 256 // 生成调色板时最多采样的像素数
+)
+
+// pngCompressionLevels PNG 压缩级别名称
+var pngCompressionLevels = map[string]png.CompressionLevel{
+	"default": png.DefaultCompression,
+	"none":    png.NoCompression,
+	"speed":   png.BestSpeed,
+	"best":    png.BestCompression,
+}
+
+// ParsePNGCompressionLevel 解析 PNG 压缩级别，空字符串表示默认级别
+func ParsePNGCompressionLevel(name string) (png.CompressionLevel, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return png.DefaultCompression, nil
+	}
+	level, ok := pngCompressionLevels[name]
+	if !ok {
+		return png.DefaultCompression, fmt.Errorf("%w: 无效的 PNG 压缩级别 %s", ErrInvalidInput, name)
+	}
+	return level, nil
+}
+
+// ValidatePaletteColors 校验调色板颜色数量，0 表示不量化
+func ValidatePaletteColors(colors int) bool {
+	return colors == 0 || (colors >= minPaletteColors && colors <= maxPaletteColors)
+}
+
+// colorBox 中位切分算法中的颜色盒
+type colorBox struct {
+	pixels []color.NRGBA
+}
+
+// channel 返回像素指定通道的值（0:R 1:G 2:B 3:A）
+func channel(c color.NRGBA, ch int) uint8 {
+	switch ch {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	case 2:
+		return c.B
+	default:
+		return c.A
+	}
+}
+
+// widestChannel 返回颜色范围最大的通道及其范围
+func (b *colorBox) widestChannel() (int, int) {
+	bestChannel, bestRange := 0, -1
+	for ch := 0; ch < 4; ch++ {
+		lo, hi := uint8(255), uint8(0)
+		for _, p := range b.pixels {
+			v := channel(p, ch)
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		if r := int(hi) - int(lo); r > bestRange {
+			bestChannel, bestRange = ch, r
+		}
+	}
+	return bestChannel, bestRange
+}
+
+// average 返回盒内像素的平均颜色
+func (b *colorBox) average() color.NRGBA {
+	var r, g, bl, a int
+	for _, p := range b.pixels {
+		r += int(p.R)
+		g += int(p.G)
+		bl += int(p.B)
+		a += int(p.A)
+	}
+	n := len(b.pixels)
+	return color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(bl / n), A: uint8(a / n)}
+}
+
+// samplePixels 以固定步长采样像素
+func samplePixels(img image.Image) []color.NRGBA {
+	bounds := img.Bounds()
+	total := bounds.Dx() * bounds.Dy()
+	step := 1
+	for total/(step*step) > maxQuantizeSample {
+		step++
+	}
+
+	pixels := make([]color.NRGBA, 0, total/(step*step)+1)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			pixels = append(pixels, color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA))
+		}
+	}
+	return pixels
+}
+
+// medianCutPalette 使用中位切分算法生成调色板
+func medianCutPalette(img image.Image, colors int) color.Palette {
+	pixels := samplePixels(img)
+	if len(pixels) == 0 {
+		return color.Palette{color.Transparent}
+	}
+
+	boxes := medianCut(pixels, colors)
+	palette := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		palette = append(palette, box.average())
+	}
+	return palette
+}
+
+// medianCut 将像素切分为最多 colors 个颜色盒，会对 pixels 重新排序
+func medianCut(pixels []color.NRGBA, colors int) []*colorBox {
+	boxes := []*colorBox{{pixels: pixels}}
+	for len(boxes) < colors {
+		// 选择颜色范围最大的盒进行切分
+		index, ch, widest := -1, 0, 0
+		for i, box := range boxes {
+			if len(box.pixels) < 2 {
+				continue
+			}
+			c, r := box.widestChannel()
+			if r > widest {
+				index, ch, widest = i, c, r
+			}
+		}
+		if index < 0 {
+			break
+		}
+
+		box := boxes[index]
+		sort.Slice(box.pixels, func(i, j int) bool {
+			return channel(box.pixels[i], ch) < channel(box.pixels[j], ch)
+		})
+		median := len(box.pixels) / 2
+		boxes[index] = &colorBox{pixels: box.pixels[:median]}
+		boxes = append(boxes, &colorBox{pixels: box.pixels[median:]})
+	}
+	return boxes
+}
+
+// quantizeImage 将图片量化为指定颜色数量的调色板图片
+func quantizeImage(img image.Image, colors int, dither bool) *image.Paletted {
+	bounds := img.Bounds()
+	dst := image.NewPaletted(bounds, medianCutPalette(img, colors))
+	if dither {
+		draw.FloydSteinberg.Draw(dst, bounds, img, bounds.Min)
+	} else {
+		draw.Draw(dst, bounds, img, bounds.Min, draw.Src)
+	}
+	return dst
+}
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestMedianCut(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	green := color.NRGBA{G: 255, A: 255}
+	blue := color.NRGBA{B: 255, A: 255}
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+
+	tests := []struct {
+		name   string
+		pixels []color.NRGBA
+		colors int
+		want   int
+	}{
+		{"切分到指定数量", []color.NRGBA{red, green, blue, white}, 4, 4},
+		{"颜色数多于像素", []color.NRGBA{red, green, blue, white}, 8, 4},
+		{"单一颜色不再切分", []color.NRGBA{red, red, red, red}, 4, 1},
+		{"只要一种颜色", []color.NRGBA{red, green, blue}, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boxes := medianCut(append([]color.NRGBA(nil), tt.pixels...), tt.colors)
+			if len(boxes) != tt.want {
+				t.Fatalf("medianCut 得到 %d 个颜色盒, want %d", len(boxes), tt.want)
+			}
+			total := 0
+			for _, box := range boxes {
+				total += len(box.pixels)
+			}
+			if total != len(tt.pixels) {
+				t.Errorf("颜色盒共 %d 个像素, want %d", total, len(tt.pixels))
+			}
+		})
+	}
+}
+
+func TestQuantizeImage(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			c := color.NRGBA{R: 200, G: 30, B: 30, A: 255}
+			if x >= 8 {
+				c = color.NRGBA{R: 20, G: 40, B: 220, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	for _, dither := range []bool{false, true} {
+		quantized := quantizeImage(img, 2, dither)
+		if len(quantized.Palette) != 2 {
+			t.Fatalf("dither=%v: 调色板有 %d 种颜色, want 2", dither, len(quantized.Palette))
+		}
+		// 只有两种颜色时量化结果与原图一致
+		for _, p := range []image.Point{{0, 0}, {7, 15}, {8, 0}, {15, 15}} {
+			want := img.NRGBAAt(p.X, p.Y)
+			got := color.NRGBAModel.Convert(quantized.At(p.X, p.Y)).(color.NRGBA)
+			if got != want {
+				t.Errorf("dither=%v: 像素 %v = %v, want %v", dither, p, got, want)
+			}
+		}
+	}
+}
+
+func TestKMeans(t *testing.T) {
+	pixels := []color.NRGBA{
+		{R: 0, G: 0, B: 0, A: 255},
+		{R: 6, G: 6, B: 6, A: 255},
+		{R: 3, G: 3, B: 3, A: 255},
+		{R: 250, G: 240, B: 230, A: 255},
+		{R: 240, G: 250, B: 230, A: 255},
+	}
+	centers := [][3]float64{{100, 100, 100}, {150, 150, 150}}
+	counts := kMeans(pixels, centers)
+	if counts[0] != 3 || counts[1] != 2 {
+		t.Fatalf("kMeans 各中心像素数 = %v, want [3 2]", counts)
+	}
+	want := [][3]float64{{3, 3, 3}, {245, 245, 230}}
+	for i := range want {
+		if centers[i] != want[i] {
+			t.Errorf("中心 %d = %v, want %v", i, centers[i], want[i])
+		}
+	}
+}
+
+func TestExtractPalette(t *testing.T) {
+	// 左侧 3/4 为红色，右侧 1/4 为蓝色，底部一行透明像素不参与统计
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 65))
+	for y := 0; y < 65; y++ {
+		for x := 0; x < 64; x++ {
+			switch {
+			case y == 64:
+				img.SetNRGBA(x, y, color.NRGBA{G: 255})
+			case x < 48:
+				img.SetNRGBA(x, y, color.NRGBA{R: 255, A: 255})
+			default:
+				img.SetNRGBA(x, y, color.NRGBA{B: 255, A: 255})
+			}
+		}
+	}
+
+	palette := extractPalette(img, 3)
+	if len(palette.Colors) != 2 {
+		t.Fatalf("提取到 %d 种主色, want 2: %+v", len(palette.Colors), palette.Colors)
+	}
+	tests := []struct {
+		hex   string
+		share float64
+	}{
+		{"#ff0000", 0.75},
+		{"#0000ff", 0.25},
+	}
+	for i, tt := range tests {
+		if got := palette.Colors[i]; got.Hex != tt.hex || got.Share != tt.share {
+			t.Errorf("主色 %d = %s %.4f, want %s %.4f", i, got.Hex, got.Share, tt.hex, tt.share)
+		}
+	}
+	if palette.Average.Hex != "#bf0040" {
+		t.Errorf("平均色 = %s, want #bf0040", palette.Average.Hex)
+	}
+}
+
+func TestNewColorValue(t *testing.T) {
+	tests := []struct {
+		r, g, b uint8
+		hex     string
+		hsl     HSLColor
+	}{
+		{255, 0, 0, "#ff0000", HSLColor{H: 0, S: 100, L: 50}},
+		{0, 255, 0, "#00ff00", HSLColor{H: 120, S: 100, L: 50}},
+		{255, 255, 255, "#ffffff", HSLColor{H: 0, S: 0, L: 100}},
+		{128, 128, 128, "#808080", HSLColor{H: 0, S: 0, L: 50.2}},
+	}
+	for _, tt := range tests {
+		got := NewColorValue(tt.r, tt.g, tt.b)
+		if got.Hex != tt.hex || got.HSL != tt.hsl {
+			t.Errorf("NewColorValue(%d, %d, %d) = %s %+v, want %s %+v", tt.r, tt.g, tt.b, got.Hex, got.HSL, tt.hex, tt.hsl)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package models
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/nfnt/resize"
+)
+
+// 缩放模式
+const (
+	ResizeFit           = "fit"            // 等比缩放到宽高范围内
+	ResizeFill          = "fill"           // 等比缩放后按锚点裁剪到指定宽高
+	ResizeStretch       = "stretch"        // 不保持宽高比直接缩放
+	ResizePercentage    = "percentage"     // 按百分比缩放
+	ResizeMaxMegapixels = "max-megapixels" // 限制最大像素数
+)
+
+const (
+	maxOutputDimension = 16384 // 输出图片的最大边长
+	maxPercentage      = 400   // percentage 模式允许的最大百分比
+)
+
+// resampleFilter 重采样滤镜在两个缩放库中的对应关系
+type resampleFilter struct {
+	imaging imaging.ResampleFilter
+	resize  resize.InterpolationFunction
+}
+
+// resampleFilters 支持的重采样滤镜
+var resampleFilters = map[string]resampleFilter{
+	"lanczos":    {imaging.Lanczos, resize.Lanczos3},
+	"catmullrom": {imaging.CatmullRom, resize.Bicubic},
+	"linear":     {imaging.Linear, resize.Bilinear},
+	"nearest":    {imaging.NearestNeighbor, resize.NearestNeighbor},
+}
+
+// ParseResizeMode 校验缩放模式，空字符串表示由 KeepAspect 决定
+func ParseResizeMode(mode string) (string, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "", ResizeFit, ResizeFill, ResizeStretch, ResizePercentage, ResizeMaxMegapixels:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%w: 无效的缩放模式 %s", ErrInvalidInput, mode)
+	}
+}
+
+// parseResampleFilter 解析重采样滤镜，空字符串表示 Lanczos
+func parseResampleFilter(name string) (resampleFilter, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = "lanczos"
+	}
+	filter, ok := resampleFilters[name]
+	if !ok {
+		return resampleFilter{}, fmt.Errorf("%w: 无效的重采样滤镜 %s", ErrInvalidInput, name)
+	}
+	return filter, nil
+}
+
+// ValidateResizeOptions 校验缩放相关选项
+func ValidateResizeOptions(options CompressionOption) error {
+	mode, err := ParseResizeMode(options.Mode)
+	if err != nil {
+		return err
+	}
+	if _, err := parseResampleFilter(options.Filter); err != nil {
+		return err
+	}
+	if _, err := parseAnchor(options.Anchor); err != nil {
+		return err
+	}
+	if options.Width < 0 || options.Height < 0 {
+		return fmt.Errorf("%w: 宽高不能为负数", ErrInvalidInput)
+	}
+	if options.Width > maxOutputDimension || options.Height > maxOutputDimension {
+		return fmt.Errorf("%w: 宽高不能超过 %d", ErrInvalidInput, maxOutputDimension)
+	}
+
+	switch resolveResizeMode(mode, options.KeepAspect) {
+	case ResizeFill:
+		if options.Width == 0 || options.Height == 0 {
+			return fmt.Errorf("%w: fill 模式需要同时指定宽度和高度", ErrInvalidInput)
+		}
+		return checkOutputSize(float64(options.Width), float64(options.Height))
+	case ResizeStretch:
+		// 同时指定宽高时输出尺寸即为宽高，无需等到解码后再校验
+		if options.Width > 0 && options.Height > 0 {
+			return checkOutputSize(float64(options.Width), float64(options.Height))
+		}
+	case ResizePercentage:
+		if options.Percentage <= 0 || options.Percentage > maxPercentage {
+			return fmt.Errorf("%w: 缩放百分比必须在 0-%d 之间", ErrInvalidInput, maxPercentage)
+		}
+	case ResizeMaxMegapixels:
+		if options.MaxMegapixels <= 0 {
+			return fmt.Errorf("%w: 最大像素数必须大于 0", ErrInvalidInput)
+		}
+	}
+	return nil
+}
+
+// resizeImage 按选项调整图片尺寸
+func resizeImage(img image.Image, options CompressionOption) (image.Image, error) {
+	if err := ValidateResizeOptions(options); err != nil {
+		return nil, err
+	}
+	filter, _ := parseResampleFilter(options.Filter)
+
+	mode, _ := ParseResizeMode(options.Mode)
+	mode = resolveResizeMode(mode, options.KeepAspect)
+
+	bounds := img.Bounds()
+	srcW, srcH := float64(bounds.Dx()), float64(bounds.Dy())
+	if srcW == 0 || srcH == 0 {
+		return img, nil
+	}
+	width, height := float64(options.Width), float64(options.Height)
+
+	switch mode {
+	case ResizeFit:
+		if width == 0 && height == 0 {
+			return img, nil
+		}
+		scale := math.Inf(1)
+		if width > 0 {
+			scale = width / srcW
+		}
+		if height > 0 {
+			scale = math.Min(scale, height/srcH)
+		}
+		return scaleImage(img, scale, options.NoUpscale, filter)
+
+	case ResizeFill:
+		anchor, _ := parseAnchor(options.Anchor)
+		scale := math.Max(width/srcW, height/srcH)
+		if options.NoUpscale && scale > 1 {
+			// 不放大时只按锚点裁剪
+			return imaging.CropAnchor(img, int(math.Min(width, srcW)), int(math.Min(height, srcH)), anchor), nil
+		}
+		if err := checkOutputSize(width, height); err != nil {
+			return nil, err
+		}
+		return imaging.Fill(img, int(width), int(height), anchor, filter.imaging), nil
+
+	case ResizeStretch:
+		if width == 0 && height == 0 {
+			return img, nil
+		}
+		if options.NoUpscale {
+			width, height = math.Min(width, srcW), math.Min(height, srcH)
+		}
+		// 只指定一边时另一边按原图比例计算，与 resize.Resize 一致
+		outW, outH := width, height
+		if outW == 0 {
+			outW = math.Round(srcW * height / srcH)
+		}
+		if outH == 0 {
+			outH = math.Round(srcH * width / srcW)
+		}
+		if err := checkOutputSize(outW, outH); err != nil {
+			return nil, err
+		}
+		return resize.Resize(uint(width), uint(height), img, filter.resize), nil
+
+	case ResizePercentage:
+		return scaleImage(img, options.Percentage/100, options.NoUpscale, filter)
+
+	case ResizeMaxMegapixels:
+		scale := math.Sqrt(options.MaxMegapixels * 1e6 / (srcW * srcH))
+		return scaleImage(img, math.Min(scale, 1), true, filter)
+	}
+	return img, nil
+}
+
+// scaleImage 按比例等比缩放图片
+func scaleImage(img image.Image, scale float64, noUpscale bool, filter resampleFilter) (image.Image, error) {
+	if scale == 1 || (noUpscale && scale > 1) {
+		return img, nil
+	}
+	bounds := img.Bounds()
+	width := int(math.Max(1, math.Round(float64(bounds.Dx())*scale)))
+	height := int(math.Max(1, math.Round(float64(bounds.Dy())*scale)))
+	if err := checkOutputSize(float64(width), float64(height)); err != nil {
+		return nil, err
+	}
+	return imaging.Resize(img, width, height, filter.imaging), nil
+}
+
+// resolveResizeMode 返回实际使用的缩放模式，未指定时由 keepAspect 决定
+func resolveResizeMode(mode string, keepAspect bool) string {
+	if mode != "" {
+		return mode
+	}
+	if keepAspect {
+		return ResizeFit
+	}
+	return ResizeStretch
+}
+
+// checkOutputSize 在分配画布前校验输出尺寸的边长与总像素数
+func checkOutputSize(width, height float64) error {
+	if width > maxOutputDimension || height > maxOutputDimension || width*height > maxInputPixels {
+		return fmt.Errorf("%w: 输出尺寸 %.0fx%.0f 超过限制（最大边长 %d，最多 %d 像素）",
+			ErrInvalidInput, width, height, maxOutputDimension, maxInputPixels)
+	}
+	return nil
+}
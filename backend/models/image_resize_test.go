@@ -0,0 +1,126 @@
+package models
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+func TestCheckOutputSize(t *testing.T) {
+	tests := []struct {
+		width, height float64
+		valid         bool
+	}{
+		{maxOutputDimension, 1, true},
+		{maxOutputDimension + 1, 1, false},
+		{1, maxOutputDimension + 1, false},
+		{7000, 7000, true},
+		{8000, 8000, false},
+	}
+	for _, tt := range tests {
+		if err := checkOutputSize(tt.width, tt.height); (err == nil) != tt.valid {
+			t.Errorf("checkOutputSize(%v, %v) = %v, want valid %v", tt.width, tt.height, err, tt.valid)
+		}
+	}
+}
+
+func TestValidateResizeOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options CompressionOption
+		valid   bool
+	}{
+		{"默认", CompressionOption{}, true},
+		{"fit 不分配目标尺寸的画布", CompressionOption{Mode: ResizeFit, Width: 8000, Height: 8000}, true},
+		{"fill 超过最大像素数", CompressionOption{Mode: ResizeFill, Width: 8000, Height: 8000}, false},
+		{"stretch 超过最大像素数", CompressionOption{Mode: ResizeStretch, Width: 8000, Height: 8000}, false},
+		{"超过最大边长", CompressionOption{Width: maxOutputDimension + 1}, false},
+		{"fill 缺少高度", CompressionOption{Mode: ResizeFill, Width: 100}, false},
+		{"百分比为 0", CompressionOption{Mode: ResizePercentage}, false},
+		{"百分比超过上限", CompressionOption{Mode: ResizePercentage, Percentage: maxPercentage + 1}, false},
+		{"百分比上限", CompressionOption{Mode: ResizePercentage, Percentage: maxPercentage}, true},
+		{"最大像素数为 0", CompressionOption{Mode: ResizeMaxMegapixels}, false},
+		{"无效的模式", CompressionOption{Mode: "crop"}, false},
+		{"无效的滤镜", CompressionOption{Filter: "box"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateResizeOptions(tt.options); (err == nil) != tt.valid {
+				t.Errorf("ValidateResizeOptions = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestResizeImage(t *testing.T) {
+	img := newBlockImage(10, 10, 1)
+
+	tests := []struct {
+		name    string
+		img     image.Image
+		options CompressionOption
+		want    image.Point // 为零值表示应返回错误
+	}{
+		{"fit", img, CompressionOption{Mode: ResizeFit, Width: 5, Height: 8}, image.Pt(5, 5)},
+		{"fill", img, CompressionOption{Mode: ResizeFill, Width: 4, Height: 2}, image.Pt(4, 2)},
+		{"stretch 只指定宽度", img, CompressionOption{Mode: ResizeStretch, Width: 20}, image.Pt(20, 20)},
+		{"stretch 按比例推算的高度超过限制", img, CompressionOption{Mode: ResizeStretch, Width: 16000}, image.Point{}},
+		{"percentage", img, CompressionOption{Mode: ResizePercentage, Percentage: 400}, image.Pt(40, 40)},
+		{"max-megapixels", img, CompressionOption{Mode: ResizeMaxMegapixels, MaxMegapixels: 0.00005}, image.Pt(7, 7)},
+		{"max-megapixels 不放大", img, CompressionOption{Mode: ResizeMaxMegapixels, MaxMegapixels: 1}, image.Pt(10, 10)},
+		{"fit 不放大", img, CompressionOption{Mode: ResizeFit, Width: 100, NoUpscale: true}, image.Pt(10, 10)},
+		{"fill 不放大时只裁剪", img, CompressionOption{Mode: ResizeFill, Width: 20, Height: 4, NoUpscale: true}, image.Pt(10, 4)},
+		{"fit 放大后超过限制", newBlockImage(1, 1, 1), CompressionOption{Mode: ResizeFit, Width: 8000, Height: 8000}, image.Point{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resizeImage(tt.img, tt.options)
+			if tt.want == (image.Point{}) {
+				if err == nil {
+					t.Errorf("resizeImage 应返回错误，得到 %v", got.Bounds().Size())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := got.Bounds().Size(); size != tt.want {
+				t.Errorf("resizeImage 尺寸 = %v, want %v", size, tt.want)
+			}
+		})
+	}
+}
+
+func TestPadToAspect(t *testing.T) {
+	white := color.White
+	tests := []struct {
+		name   string
+		img    image.Image
+		aspect float64
+		want   image.Point // 为零值表示应返回错误
+	}{
+		{"横向填充", newBlockImage(10, 10, 1), 2, image.Pt(20, 10)},
+		{"纵向填充", newBlockImage(10, 10, 1), 0.5, image.Pt(10, 20)},
+		{"比例相同", newBlockImage(20, 10, 1), 2, image.Pt(20, 10)},
+		{"填充后超过最大边长", newUniformImage(16000, 1, color.NRGBA{}), 1, image.Point{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := padToAspect(tt.img, tt.aspect, imaging.Center, white)
+			if tt.want == (image.Point{}) {
+				if err == nil {
+					t.Errorf("padToAspect 应返回错误，得到 %v", got.Bounds().Size())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := got.Bounds().Size(); size != tt.want {
+				t.Errorf("padToAspect 尺寸 = %v, want %v", size, tt.want)
+			}
+		})
+	}
+}
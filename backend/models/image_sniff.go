@@ -0,0 +1,143 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"mime"
+	"strings"
+)
+
+// 上传内容校验错误码
+const (
+	CodeUnsupportedContent  = "UNSUPPORTED_CONTENT"   // 文件内容不是支持的图片格式
+	CodeExtensionMismatch   = "EXTENSION_MISMATCH"    // 扩展名与实际内容不符
+	CodeContentTypeMismatch = "CONTENT_TYPE_MISMATCH" // Content-Type 与实际内容不符
+	CodePolyglotFile        = "POLYGLOT_FILE"         // 文件同时是其他格式（如 ZIP/HTML/脚本）
+	CodeDimensionsTooLarge  = "DIMENSIONS_TOO_LARGE"  // 图片尺寸超过限制
+	CodeCorruptImage        = "CORRUPT_IMAGE"         // 无法读取图片头信息
+)
+
+const (
+	maxInputPixels    = 50_000_000 // 允许解码的最大像素数
+	maxInputDimension = 20000      // 允许解码的最大边长
+	zipTailScanSize   = 64*1024 + 22
+)
+
+// ImageValidationError 上传内容校验错误
+type ImageValidationError struct {
+	Code    string
+	Message string
+}
+
+// Error 实现 error 接口
+func (e *ImageValidationError) Error() string {
+	return e.Message
+}
+
+// newValidationError 创建上传内容校验错误
+func newValidationError(code, format string, args ...interface{}) *ImageValidationError {
+	return &ImageValidationError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// magicSignatures 各格式的文件头
+var magicSignatures = []struct {
+	format string
+	match  func([]byte) bool
+}{
+	{"jpeg", func(b []byte) bool { return bytes.HasPrefix(b, []byte{0xFF, 0xD8, 0xFF}) }},
+	{"png", func(b []byte) bool { return bytes.HasPrefix(b, []byte("\x89PNG\r\n\x1a\n")) }},
+	{"gif", func(b []byte) bool {
+		return bytes.HasPrefix(b, []byte("GIF87a")) || bytes.HasPrefix(b, []byte("GIF89a"))
+	}},
+	{"bmp", func(b []byte) bool { return bytes.HasPrefix(b, []byte("BM")) && len(b) >= 26 }},
+	{"tiff", func(b []byte) bool {
+		return bytes.HasPrefix(b, []byte("II*\x00")) || bytes.HasPrefix(b, []byte("MM\x00*"))
+	}},
+	{"webp", func(b []byte) bool {
+		return len(b) >= 12 && bytes.HasPrefix(b, []byte("RIFF")) && bytes.Equal(b[8:12], []byte("WEBP"))
+	}},
+}
+
+// contentTypes 各格式可接受的 Content-Type
+var contentTypes = map[string][]string{
+	"jpeg": {"image/jpeg", "image/jpg", "image/pjpeg"},
+	"png":  {"image/png", "image/x-png"},
+	"gif":  {"image/gif"},
+	"bmp":  {"image/bmp", "image/x-bmp", "image/x-ms-bmp"},
+	"tiff": {"image/tiff", "image/x-tiff"},
+	"webp": {"image/webp"},
+}
+
+// DetectFormat 根据文件头识别图片格式
+func DetectFormat(header []byte) (string, bool) {
+	for _, sig := range magicSignatures {
+		if sig.match(header) {
+			return sig.format, true
+		}
+	}
+	return "", false
+}
+
+// ValidateUpload 校验上传内容：识别真实格式，并检查扩展名、Content-Type、混合文件与图片尺寸
+// contentType 为空或为 application/octet-stream 时不校验 Content-Type
+func (s *DefaultImageService) ValidateUpload(filename, contentType string, data []byte) (string, error) {
+	format, ok := DetectFormat(data)
+	if !ok {
+		return "", newValidationError(CodeUnsupportedContent, "文件内容不是支持的图片格式")
+	}
+
+	info, ok := lookupFormat(filename)
+	if !ok || info.Decoder != format {
+		return "", newValidationError(CodeExtensionMismatch, "文件扩展名与实际内容（%s）不符", format)
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType != "application/octet-stream" {
+		matched := false
+		for _, accepted := range contentTypes[format] {
+			if strings.EqualFold(mediaType, accepted) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return "", newValidationError(CodeContentTypeMismatch, "Content-Type %s 与实际内容（%s）不符", mediaType, format)
+		}
+	}
+
+	if isPolyglot(format, data) {
+		return "", newValidationError(CodePolyglotFile, "文件同时包含其他格式的内容")
+	}
+
+	if _, err := checkDimensions(data); err != nil {
+		return "", err
+	}
+	return format, nil
+}
+
+// checkDimensions 读取图片头中的尺寸，超过限制时返回错误，防止解压炸弹耗尽内存
+func checkDimensions(data []byte) (image.Config, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return config, newValidationError(CodeCorruptImage, "无法读取图片信息: %v", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 ||
+		config.Width > maxInputDimension || config.Height > maxInputDimension ||
+		int64(config.Width)*int64(config.Height) > maxInputPixels {
+		return config, newValidationError(CodeDimensionsTooLarge, "图片尺寸 %dx%d 超过限制（最大边长 %d，最多 %d 像素）",
+			config.Width, config.Height, maxInputDimension, maxInputPixels)
+	}
+	return config, nil
+}
+
+// decodeImage 检查尺寸后解码图片
+func decodeImage(data []byte) (image.Image, string, error) {
+	if _, err := checkDimensions(data); err != nil {
+		return nil, "", err
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("无法解码图片: %v", err)
+	}
+	return img, format, nil
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+// pngHeader 生成只包含文件头与 IHDR 的 PNG，用于测试尺寸校验而无需分配像素
+func pngHeader(width, height uint32) []byte {
+	ihdr := []byte("IHDR")
+	ihdr = binary.BigEndian.AppendUint32(ihdr, width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0) // 8 位 RGBA
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = binary.BigEndian.AppendUint32(data, uint32(len(ihdr)-4))
+	data = append(data, ihdr...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(ihdr))
+}
+
+func TestCheckDimensions(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height uint32
+		valid         bool
+	}{
+		{"普通尺寸", 1920, 1080, true},
+		{"最大边长", maxInputDimension, 1, true},
+		{"超过最大边长", maxInputDimension + 1, 1, false},
+		{"接近最大像素数", 7000, 7000, true},
+		{"超过最大像素数", 8000, 8000, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := checkDimensions(pngHeader(tt.width, tt.height))
+			if tt.valid {
+				if err != nil || config.Width != int(tt.width) || config.Height != int(tt.height) {
+					t.Errorf("checkDimensions = %+v, %v", config, err)
+				}
+				return
+			}
+			var verr *ImageValidationError
+			if !errors.As(err, &verr) || verr.Code != CodeDimensionsTooLarge {
+				t.Errorf("checkDimensions 错误 = %v, want %s", err, CodeDimensionsTooLarge)
+			}
+		})
+	}
+}
+
+func TestValidateUpload(t *testing.T) {
+	pngData := encodeTestImage(t, newBlockImage(16, 16, 1), "png")
+	service := &DefaultImageService{}
+
+	tests := []struct {
+		name, filename, contentType string
+		data                        []byte
+		code                        string // 为空表示校验通过
+	}{
+		{"合法 PNG", "a.png", "image/png", pngData, ""},
+		{"未指定 Content-Type", "a.png", "application/octet-stream", pngData, ""},
+		{"不是图片", "a.png", "image/png", []byte("hello"), CodeUnsupportedContent},
+		{"扩展名不符", "a.jpg", "image/png", pngData, CodeExtensionMismatch},
+		{"Content-Type 不符", "a.png", "image/jpeg", pngData, CodeContentTypeMismatch},
+		{"混合文件", "a.png", "image/png", append(append([]byte(nil), pngData...), "<?php"...), CodePolyglotFile},
+		{"尺寸过大", "a.png", "image/png", pngHeader(8000, 8000), CodeDimensionsTooLarge},
+		{"图片头损坏", "a.png", "image/png", []byte("\x89PNG\r\n\x1a\n\x00"), CodeCorruptImage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := service.ValidateUpload(tt.filename, tt.contentType, tt.data)
+			if tt.code == "" {
+				if err != nil || format != "png" {
+					t.Errorf("ValidateUpload = %q, %v", format, err)
+				}
+				return
+			}
+			var verr *ImageValidationError
+			if !errors.As(err, &verr) || verr.Code != tt.code {
+				t.Errorf("ValidateUpload 错误 = %v, want %s", err, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	minTargetQuality   = 10  // 目标大小模式允许的最低 JPEG 质量
+	minDownscaleSide   = 16  // 缩小尺寸时允许的最短边
+	maxDownscaleRounds = 8   // 最多缩小尺寸的轮数
+	downscaleStep      = 0.9 // 每轮缩放系数的上限
+)
+
+// encodedImage 编码结果
+type encodedImage struct {
+	Data       []byte
+	Quality    int         // 实际使用的 JPEG 质量，非 JPEG 输出为 0
+	Iterations int         // 编码次数
+	Width      int         // 编码后的宽度
+	Height     int         // 编码后的高度
+	Image      image.Image // 实际编码的图片，目标大小模式缩小尺寸后与输入不同
+}
+
+// encodeToBytes 将图片编码到内存
+func encodeToBytes(img image.Image, format string, params encodeParams) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeImage(&buf, img, format, params); err != nil {
+		return nil, fmt.Errorf("编码图片失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeOutput 按选项编码图片，设置了目标大小时搜索满足大小的参数
+func encodeOutput(ctx context.Context, img image.Image, format string, options CompressionOption) (*encodedImage, error) {
+	pngLevel, err := ParsePNGCompressionLevel(options.PNGCompression)
+	if err != nil {
+		return nil, err
+	}
+	params := encodeParams{PNGLevel: pngLevel}
+	if format == "jpeg" {
+		params.Quality = options.Quality
+		if params.Quality <= 0 || params.Quality > 100 {
+			params.Quality = 85 // 默认质量
+		}
+	}
+
+	if options.TargetSize <= 0 {
+		data, err := encodeToBytes(img, format, params)
+		if err != nil {
+			return nil, err
+		}
+		bounds := img.Bounds()
+		return &encodedImage{Data: data, Quality: params.Quality, Iterations: 1, Width: bounds.Dx(), Height: bounds.Dy(), Image: img}, nil
+	}
+
+	iterations := 0
+	current := img
+	var smallest int
+	for round := 0; ; round++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if round > 0 {
+			reportProgress(ctx, StageEncoding, 70+25*round/(maxDownscaleRounds+1))
+		}
+		data, q, n, err := searchQuality(current, format, params, options.TargetSize)
+		iterations += n
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) <= options.TargetSize {
+			bounds := current.Bounds()
+			return &encodedImage{Data: data, Quality: q, Iterations: iterations, Width: bounds.Dx(), Height: bounds.Dy(), Image: current}, nil
+		}
+		smallest = len(data)
+
+		if !options.AllowDownscale || round >= maxDownscaleRounds {
+			break
+		}
+
+		// 按面积与字节数近似成正比估算缩放系数
+		scale := math.Min(math.Sqrt(float64(options.TargetSize)/float64(len(data))), downscaleStep)
+		bounds := current.Bounds()
+		width := int(float64(bounds.Dx()) * scale)
+		height := int(float64(bounds.Dy()) * scale)
+		if width < minDownscaleSide || height < minDownscaleSide {
+			break
+		}
+		current = imaging.Resize(current, width, height, imaging.Lanczos)
+	}
+
+	return nil, fmt.Errorf("%w: 最小可达 %d 字节，目标 %d 字节", ErrTargetSizeUnreachable, smallest, options.TargetSize)
+}
+
+// searchQuality 二分查找不超过目标大小的最高 JPEG 质量
+// 非 JPEG 格式没有质量参数，只编码一次
+// 返回满足条件的结果，若均不满足则返回最低质量的结果
+func searchQuality(img image.Image, format string, params encodeParams, target int64) ([]byte, int, int, error) {
+	if format != "jpeg" {
+		data, err := encodeToBytes(img, format, params)
+		return data, 0, 1, err
+	}
+
+	iterations := 0
+	low, high := minTargetQuality, params.Quality
+	if high < low {
+		high = low
+	}
+
+	var best []byte
+	bestQuality := 0
+	var fallback []byte
+	for low <= high {
+		mid := (low + high) / 2
+		params.Quality = mid
+		data, err := encodeToBytes(img, format, params)
+		iterations++
+		if err != nil {
+			return nil, 0, iterations, err
+		}
+		if int64(len(data)) <= target {
+			best, bestQuality = data, mid
+			low = mid + 1
+		} else {
+			if mid == minTargetQuality {
+				fallback = data
+			}
+			high = mid - 1
+		}
+	}
+
+	// 二分查找失败时最后一次尝试必定是最低质量
+	if best == nil {
+		return fallback, minTargetQuality, iterations, nil
+	}
+	return best, bestQuality, iterations, nil
+}
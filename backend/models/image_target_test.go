@@ -0,0 +1,91 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSearchQuality(t *testing.T) {
+	img := newBlockImage(128, 128, 11)
+	full, err := encodeToBytes(img, "jpeg", encodeParams{Quality: 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lowest, err := encodeToBytes(img, "jpeg", encodeParams{Quality: minTargetQuality})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		target  int64
+		quality int // 为 0 表示只要求结果不超过目标
+	}{
+		{"原始质量已满足", int64(len(full)), 90},
+		{"需要降低质量", int64(len(full)+len(lowest)) / 2, 0},
+		{"最低质量恰好满足", int64(len(lowest)), 0},
+		{"无法达到", int64(len(lowest)) - 1, minTargetQuality},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, quality, iterations, err := searchQuality(img, "jpeg", encodeParams{Quality: 90}, tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if iterations < 1 || iterations > 7 {
+				t.Errorf("编码次数 = %d, want 1-7", iterations)
+			}
+			if quality < minTargetQuality || quality > 90 || (tt.quality != 0 && quality != tt.quality) {
+				t.Errorf("质量 = %d, want %d", quality, tt.quality)
+			}
+			if fits := int64(len(data)) <= tt.target; fits != (tt.target >= int64(len(lowest))) {
+				t.Errorf("结果 %d 字节，目标 %d 字节", len(data), tt.target)
+			}
+		})
+	}
+
+	// 非 JPEG 格式只编码一次
+	if _, quality, iterations, err := searchQuality(img, "png", encodeParams{}, 1); err != nil || quality != 0 || iterations != 1 {
+		t.Errorf("png: quality = %d, iterations = %d, err = %v", quality, iterations, err)
+	}
+}
+
+func TestEncodeOutputTargetSize(t *testing.T) {
+	img := newBlockImage(256, 192, 12)
+	lowest, err := encodeToBytes(img, "jpeg", encodeParams{Quality: minTargetQuality})
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := int64(len(lowest)) / 3
+
+	t.Run("不允许缩小尺寸", func(t *testing.T) {
+		_, err := encodeOutput(context.Background(), img, "jpeg", CompressionOption{TargetSize: target})
+		if !errors.Is(err, ErrTargetSizeUnreachable) {
+			t.Errorf("encodeOutput 错误 = %v, want ErrTargetSizeUnreachable", err)
+		}
+	})
+
+	t.Run("允许缩小尺寸", func(t *testing.T) {
+		out, err := encodeOutput(context.Background(), img, "jpeg", CompressionOption{TargetSize: target, AllowDownscale: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int64(len(out.Data)) > target {
+			t.Errorf("结果 %d 字节，目标 %d 字节", len(out.Data), target)
+		}
+		if out.Width >= 256 || out.Height >= 192 {
+			t.Errorf("尺寸 = %dx%d，应小于原图", out.Width, out.Height)
+		}
+		if size := out.Image.Bounds().Size(); size.X != out.Width || size.Y != out.Height {
+			t.Errorf("Image 尺寸 = %v, want %dx%d", size, out.Width, out.Height)
+		}
+	})
+
+	t.Run("缩小到最短边仍无法达到", func(t *testing.T) {
+		_, err := encodeOutput(context.Background(), img, "jpeg", CompressionOption{TargetSize: 100, AllowDownscale: true})
+		if !errors.Is(err, ErrTargetSizeUnreachable) {
+			t.Errorf("encodeOutput 错误 = %v, want ErrTargetSizeUnreachable", err)
+		}
+	})
+}
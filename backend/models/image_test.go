@@ -0,0 +1,94 @@
+package models
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// newBlockImage 生成由 8x8 像素色块组成的测试图片，色块颜色由种子确定
+func newBlockImage(width, height int, seed uint32) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	blocks := make(map[image.Point]color.NRGBA)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			block := image.Pt(x/8, y/8)
+			c, ok := blocks[block]
+			if !ok {
+				seed = seed*1664525 + 1013904223
+				c = color.NRGBA{R: uint8(seed >> 24), G: uint8(seed >> 16), B: uint8(seed >> 8), A: 0xff}
+				blocks[block] = c
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// newUniformImage 生成纯色测试图片
+func newUniformImage(width, height int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+// encodeTestImage 将测试图片编码为指定格式
+func encodeTestImage(t *testing.T, img image.Image, format string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		t.Fatalf("不支持的测试格式 %s", format)
+	}
+	if err != nil {
+		t.Fatalf("编码测试图片失败: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestOutputFilename(t *testing.T) {
+	tests := []struct {
+		filename, format, want string
+	}{
+		{"a.jpg", "", "a.jpg"},
+		{"a.webp", "", "a.png"},
+		{"a.png", "jpeg", "a.jpg"},
+		{"a.jpeg", "jpeg", "a.jpeg"},
+		{"a.tif", "tiff", "a.tif"},
+		{"a.bmp", "unknown", "a.bmp"},
+	}
+	for _, tt := range tests {
+		if got := OutputFilename(tt.filename, tt.format); got != tt.want {
+			t.Errorf("OutputFilename(%q, %q) = %q, want %q", tt.filename, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestKeptOriginalFilename(t *testing.T) {
+	tests := []struct {
+		key, format, want string
+	}{
+		{"0123.png", "webp", "0123.webp"},
+		{"0123.png", "jpeg", "0123.jpg"},
+		{"0123.png", "png", "0123.png"},
+		{"0123.png", "unknown", "0123.png"},
+	}
+	for _, tt := range tests {
+		if got := keptOriginalFilename(tt.key, tt.format); got != tt.want {
+			t.Errorf("keptOriginalFilename(%q, %q) = %q, want %q", tt.key, tt.format, got, tt.want)
+		}
+	}
+}
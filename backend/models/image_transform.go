@@ -0,0 +1,250 @@
+package models
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// 变换操作类型
+const (
+	TransformCrop       = "crop"        // 按矩形裁剪
+	TransformCropAnchor = "crop-anchor" // 按锚点裁剪到指定尺寸
+	TransformRotate     = "rotate"      // 顺时针旋转
+	TransformFlip       = "flip"        // 水平或垂直翻转
+	TransformPad        = "pad"         // 填充到指定宽高比
+)
+
+const (
+	maxTransforms = 20  // 单次请求允许的最大变换操作数
+	maxPadAspect  = 100 // pad 宽高比的上限，下限为其倒数
+)
+
+// TransformOp 图片变换操作
+type TransformOp struct {
+	Type      string  `json:"type"`                // 操作类型
+	X         int     `json:"x,omitempty"`         // crop: 左上角横坐标
+	Y         int     `json:"y,omitempty"`         // crop: 左上角纵坐标
+	Width     int     `json:"width,omitempty"`     // crop/crop-anchor: 宽度
+	Height    int     `json:"height,omitempty"`    // crop/crop-anchor: 高度
+	Anchor    string  `json:"anchor,omitempty"`    // crop-anchor/pad: 锚点，默认 center
+	Angle     float64 `json:"angle,omitempty"`     // rotate: 顺时针角度
+	Fill      string  `json:"fill,omitempty"`      // rotate/pad: 空白区域填充色，默认透明
+	Direction string  `json:"direction,omitempty"` // flip: h 或 v
+	Aspect    string  `json:"aspect,omitempty"`    // pad: 目标宽高比，如 16:9
+}
+
+// anchors 锚点名称
+var anchors = map[string]imaging.Anchor{
+	"center":       imaging.Center,
+	"top-left":     imaging.TopLeft,
+	"top":          imaging.Top,
+	"top-right":    imaging.TopRight,
+	"left":         imaging.Left,
+	"right":        imaging.Right,
+	"bottom-left":  imaging.BottomLeft,
+	"bottom":       imaging.Bottom,
+	"bottom-right": imaging.BottomRight,
+}
+
+// parseAnchor 解析锚点名称，空字符串表示居中
+func parseAnchor(name string) (imaging.Anchor, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return imaging.Center, nil
+	}
+	anchor, ok := anchors[name]
+	if !ok {
+		return imaging.Center, fmt.Errorf("%w: 无效的锚点 %s", ErrInvalidInput, name)
+	}
+	return anchor, nil
+}
+
+// parseFill 解析填充色，空字符串表示透明
+func parseFill(fill string) (color.NRGBA, error) {
+	if fill == "" {
+		return color.NRGBA{}, nil
+	}
+	return ParseHexColor(fill)
+}
+
+// parseAspect 解析 "16:9" 形式的宽高比
+func parseAspect(aspect string) (float64, error) {
+	w, h, found := strings.Cut(aspect, ":")
+	if !found {
+		return 0, fmt.Errorf("%w: 无效的宽高比 %s", ErrInvalidInput, aspect)
+	}
+	width, err1 := strconv.ParseFloat(strings.TrimSpace(w), 64)
+	height, err2 := strconv.ParseFloat(strings.TrimSpace(h), 64)
+	if err1 != nil || err2 != nil || width <= 0 || height <= 0 {
+		return 0, fmt.Errorf("%w: 无效的宽高比 %s", ErrInvalidInput, aspect)
+	}
+	ratio := width / height
+	if math.IsNaN(ratio) || ratio > maxPadAspect || ratio < 1.0/maxPadAspect {
+		return 0, fmt.Errorf("%w: 宽高比必须在 1:%d 到 %d:1 之间", ErrInvalidInput, maxPadAspect, maxPadAspect)
+	}
+	return ratio, nil
+}
+
+// ValidateTransforms 校验变换操作参数
+func ValidateTransforms(ops []TransformOp) error {
+	if len(ops) > maxTransforms {
+		return fmt.Errorf("%w: 变换操作最多 %d 个", ErrInvalidInput, maxTransforms)
+	}
+	for i, op := range ops {
+		if err := op.validate(); err != nil {
+			return fmt.Errorf("第 %d 个变换操作: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// validate 校验单个变换操作的参数
+func (op TransformOp) validate() error {
+	switch op.Type {
+	case TransformCrop:
+		if op.X < 0 || op.Y < 0 || op.Width <= 0 || op.Height <= 0 {
+			return fmt.Errorf("%w: 裁剪区域无效", ErrInvalidInput)
+		}
+	case TransformCropAnchor:
+		if op.Width <= 0 || op.Height <= 0 {
+			return fmt.Errorf("%w: 裁剪尺寸无效", ErrInvalidInput)
+		}
+		if _, err := parseAnchor(op.Anchor); err != nil {
+			return err
+		}
+	case TransformRotate:
+		if math.IsNaN(op.Angle) || math.IsInf(op.Angle, 0) {
+			return fmt.Errorf("%w: 旋转角度无效", ErrInvalidInput)
+		}
+		if _, err := parseFill(op.Fill); err != nil {
+			return err
+		}
+	case TransformFlip:
+		if op.Direction != "h" && op.Direction != "v" {
+			return fmt.Errorf("%w: 翻转方向只能是 h 或 v", ErrInvalidInput)
+		}
+	case TransformPad:
+		if _, err := parseAspect(op.Aspect); err != nil {
+			return err
+		}
+		if _, err := parseAnchor(op.Anchor); err != nil {
+			return err
+		}
+		if _, err := parseFill(op.Fill); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: 未知的变换操作 %s", ErrInvalidInput, op.Type)
+	}
+	return nil
+}
+
+// applyTransforms 按顺序执行变换操作
+func applyTransforms(img image.Image, ops []TransformOp) (image.Image, error) {
+	if err := ValidateTransforms(ops); err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		var err error
+		if img, err = op.apply(img); err != nil {
+			return nil, fmt.Errorf("第 %d 个变换操作: %w", i+1, err)
+		}
+	}
+	return img, nil
+}
+
+// apply 执行单个变换操作，参数已校验
+func (op TransformOp) apply(img image.Image) (image.Image, error) {
+	switch op.Type {
+	case TransformCrop:
+		bounds := img.Bounds()
+		rect := image.Rect(op.X, op.Y, op.X+op.Width, op.Y+op.Height).Add(bounds.Min).Intersect(bounds)
+		if rect.Empty() {
+			return nil, fmt.Errorf("%w: 裁剪区域超出图片范围", ErrInvalidInput)
+		}
+		return imaging.Crop(img, rect), nil
+
+	case TransformCropAnchor:
+		anchor, _ := parseAnchor(op.Anchor)
+		return imaging.CropAnchor(img, op.Width, op.Height, anchor), nil
+
+	case TransformRotate:
+		// imaging 的角度为逆时针方向
+		angle := math.Mod(op.Angle, 360)
+		if angle < 0 {
+			angle += 360
+		}
+		switch angle {
+		case 0:
+			return img, nil
+		case 90:
+			return imaging.Rotate270(img), nil
+		case 180:
+			return imaging.Rotate180(img), nil
+		case 270:
+			return imaging.Rotate90(img), nil
+		}
+		fill, _ := parseFill(op.Fill)
+		return imaging.Rotate(img, -angle, fill), nil
+
+	case TransformFlip:
+		if op.Direction == "h" {
+			return imaging.FlipH(img), nil
+		}
+		return imaging.FlipV(img), nil
+
+	case TransformPad:
+		aspect, _ := parseAspect(op.Aspect)
+		anchor, _ := parseAnchor(op.Anchor)
+		fill, _ := parseFill(op.Fill)
+		return padToAspect(img, aspect, anchor, fill)
+	}
+	return img, nil
+}
+
+// padToAspect 在图片四周填充到指定宽高比，填充后的尺寸在分配画布前校验
+func padToAspect(img image.Image, aspect float64, anchor imaging.Anchor, fill color.Color) (image.Image, error) {
+	bounds := img.Bounds()
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+	if w/h < aspect {
+		w = math.Round(h * aspect)
+	} else {
+		h = math.Round(w / aspect)
+	}
+	if w > maxOutputDimension || h > maxOutputDimension || w*h > maxInputPixels {
+		return nil, fmt.Errorf("%w: 填充后尺寸 %.0fx%.0f 超过限制（最大边长 %d，最多 %d 像素）",
+			ErrInvalidInput, w, h, maxOutputDimension, maxInputPixels)
+	}
+	width, height := int(w), int(h)
+	if width == bounds.Dx() && height == bounds.Dy() {
+		return img, nil
+	}
+
+	canvas := imaging.New(width, height, fill)
+	return imaging.Paste(canvas, img, anchorPoint(anchor, width, height, bounds.Dx(), bounds.Dy())), nil
+}
+
+// anchorPoint 计算内容在画布中按锚点放置时的左上角坐标
+func anchorPoint(anchor imaging.Anchor, canvasW, canvasH, w, h int) image.Point {
+	x := (canvasW - w) / 2
+	y := (canvasH - h) / 2
+	switch anchor {
+	case imaging.TopLeft, imaging.Left, imaging.BottomLeft:
+		x = 0
+	case imaging.TopRight, imaging.Right, imaging.BottomRight:
+		x = canvasW - w
+	}
+	switch anchor {
+	case imaging.TopLeft, imaging.Top, imaging.TopRight:
+		y = 0
+	case imaging.BottomLeft, imaging.Bottom, imaging.BottomRight:
+		y = canvasH - h
+	}
+	return image.Pt(x, y)
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 按需处理的默认限制
+const (
+	defaultURLMaxDimension  = 4096 // 最大宽高
+	defaultURLMaxFilterCost = 20   // 非预设参数中滤镜的总开销
+	defaultURLMaxVariants   = 20   // 每张图片允许的非预设参数组合数
+)
+
+// URLTransformPolicy 按 URL 参数处理图片的限制，防止任意参数组合消耗 CPU
+type URLTransformPolicy struct {
+	MaxDimension  int               // 宽高上限，0 表示使用默认值
+	MaxFilterCost int               // 非预设参数中滤镜的总开销上限，按 filterCost 计算，0 表示使用默认值
+	MaxVariants   int               // 每张图片允许的非预设参数组合数，0 表示使用默认值
+	Presets       map[string]string // 预设名称 -> 参数串
+	PresetsOnly   bool              // 是否只允许使用预设
+}
+
+// IsPreset 判断参数串是否为单个预设名称，预设由管理员配置，不受开销与组合数限制
+func (p URLTransformPolicy) IsPreset(params string) bool {
+	_, ok := p.Presets[params]
+	return ok
+}
+
+// VariantLimit 返回每张图片允许的非预设参数组合数
+func (p URLTransformPolicy) VariantLimit() int {
+	if p.MaxVariants <= 0 {
+		return defaultURLMaxVariants
+	}
+	return p.MaxVariants
+}
+
+// ParseURLPresets 解析 "thumb:w_200,h_200,fit_fill;hero:w_1920,q_80" 形式的预设列表
+func ParseURLPresets(value string) (map[string]string, error) {
+	presets := make(map[string]string)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, params, found := strings.Cut(entry, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || strings.ContainsAny(name, ",_") {
+			return nil, fmt.Errorf("无效的预设: %s", entry)
+		}
+		presets[name] = strings.TrimSpace(params)
+	}
+	return presets, nil
+}
+
+// Validate 校验预设中的参数
+func (p URLTransformPolicy) Validate() error {
+	for name, params := range p.Presets {
+		if _, err := p.parseTokens(params, false); err != nil {
+			return fmt.Errorf("预设 %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Parse 将 URL 中的参数串转换为压缩选项
+// 参数以逗号分隔，如 w_400,h_300,q_70,fit_fill,f_grayscale,f_blur:2，也可以直接使用预设名称
+// 只允许预设时，参数串必须是单个预设名称
+func (p URLTransformPolicy) Parse(params string) (CompressionOption, error) {
+	if preset, ok := p.Presets[params]; ok {
+		return p.parseTokens(preset, false)
+	}
+	if p.PresetsOnly {
+		return CompressionOption{}, fmt.Errorf("%w: 未知的预设 %s", ErrInvalidInput, params)
+	}
+	options, err := p.parseTokens(params, true)
+	if err != nil {
+		return options, err
+	}
+
+	// 限制滤镜总开销，避免如 20 次大半径模糊的组合
+	maxCost := p.MaxFilterCost
+	if maxCost <= 0 {
+		maxCost = defaultURLMaxFilterCost
+	}
+	cost := 0
+	for _, op := range options.Filters {
+		cost += filterCost(op)
+	}
+	if cost > maxCost {
+		return options, fmt.Errorf("%w: 滤镜开销 %d 超过上限 %d，请减少滤镜或减小模糊与锐化半径", ErrInvalidInput, cost, maxCost)
+	}
+	return options, nil
+}
+
+// parseTokens 解析参数串，allowPresets 为 true 时参数中可以引用预设
+func (p URLTransformPolicy) parseTokens(params string, allowPresets bool) (CompressionOption, error) {
+	options := CompressionOption{
+		Quality:    85,
+		KeepAspect: true,
+		NoUpscale:  true, // 按需处理不放大图片
+	}
+	maxDimension := p.MaxDimension
+	if maxDimension <= 0 {
+		maxDimension = defaultURLMaxDimension
+	}
+
+	tokens := strings.Split(params, ",")
+	for i := 0; i < len(tokens); i++ {
+		token := strings.TrimSpace(tokens[i])
+		if token == "" || token == "_" {
+			continue
+		}
+		if preset, ok := p.Presets[token]; ok && allowPresets {
+			// 预设展开后与后续参数合并，后出现的参数覆盖前面的
+			tokens = append(tokens[:i+1], append(strings.Split(preset, ","), tokens[i+1:]...)...)
+			continue
+		}
+
+		key, value, found := strings.Cut(token, "_")
+		if !found || value == "" {
+			return options, fmt.Errorf("%w: 无效的参数 %s", ErrInvalidInput, token)
+		}
+		switch key {
+		case "w", "h":
+			size, err := strconv.Atoi(value)
+			if err != nil || size <= 0 || size > maxDimension {
+				return options, fmt.Errorf("%w: 宽高必须在 1-%d 之间", ErrInvalidInput, maxDimension)
+			}
+			if key == "w" {
+				options.Width = uint(size)
+			} else {
+				options.Height = uint(size)
+			}
+		case "q":
+			quality, err := strconv.Atoi(value)
+			if err != nil || quality < 1 || quality > 100 {
+				return options, fmt.Errorf("%w: 质量必须在 1-100 之间", ErrInvalidInput)
+			}
+			options.Quality = quality
+		case "fit":
+			switch value {
+			case ResizeFit, ResizeFill, ResizeStretch:
+				options.Mode = value
+			default:
+				return options, fmt.Errorf("%w: 缩放模式只能是 fit、fill 或 stretch", ErrInvalidInput)
+			}
+		case "g":
+			if _, err := parseAnchor(value); err != nil {
+				return options, err
+			}
+			options.Anchor = value
+		case "bg":
+			if _, err := ParseHexColor(value); err != nil {
+				return options, err
+			}
+			options.Background = "#" + value
+		case "f":
+			// 滤镜按出现顺序执行
+			if len(options.Filters) >= maxFilters {
+				return options, fmt.Errorf("%w: 滤镜最多 %d 个", ErrInvalidInput, maxFilters)
+			}
+			filter, err := ParseFilterToken(value)
+			if err != nil {
+				return options, err
+			}
+			options.Filters = append(options.Filters, filter)
+		default:
+			return options, fmt.Errorf("%w: 未知的参数 %s", ErrInvalidInput, key)
+		}
+	}
+
+	if options.Mode == ResizeFill && (options.Width == 0 || options.Height == 0) {
+		return options, fmt.Errorf("%w: fill 模式需要同时指定宽高", ErrInvalidInput)
+	}
+	if err := ValidateResizeOptions(options); err != nil {
+		return options, err
+	}
+	return options, nil
+}
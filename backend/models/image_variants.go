@@ -0,0 +1,194 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+)
+
+// 响应式图片尺寸限制
+const (
+	maxVariantWidths  = 10   // 单次最多生成的宽度数
+	maxVariantFormats = 3    // 单次最多生成的格式数
+	maxVariantWidth   = 8192 // 单个尺寸的最大宽度
+)
+
+// DefaultVariantWidths 未指定宽度时生成的尺寸
+var DefaultVariantWidths = []int{320, 640, 1024, 1920}
+
+// VariantSpec 响应式图片生成参数
+type VariantSpec struct {
+	Widths  []int             // 目标宽度，超过原图宽度的按原图宽度生成
+	Formats []string          // 输出格式，为空时沿用原格式，第一个作为 <img> 的默认格式
+	Options CompressionOption // 共享的压缩选项，尺寸与输出格式由每个尺寸单独设置
+}
+
+// ImageVariant 响应式图片中的单个尺寸
+type ImageVariant struct {
+	Width    int               `json:"width"`    // 实际宽度
+	Height   int               `json:"height"`   // 实际高度
+	Format   string            `json:"format"`   // 输出格式
+	Filename string            `json:"filename"` // 压缩结果文件名
+	URL      string            `json:"url"`      // 访问地址
+	Size     int64             `json:"size"`     // 文件大小（字节）
+	Cached   bool              `json:"cached"`   // 命中缓存
+	Result   *CompressResult   `json:"-"`        // 压缩结果，用于写入图片目录
+	Options  CompressionOption `json:"-"`        // 生成该尺寸使用的压缩选项
+}
+
+// VariantSet 响应式图片的所有尺寸，可直接作为 JSON 清单返回
+type VariantSet struct {
+	SourceSHA256 string         `json:"sourceSha256"` // 原始文件内容的 SHA-256
+	Width        int            `json:"width"`        // 原图宽度（方向校正后）
+	Height       int            `json:"height"`       // 原图高度（方向校正后）
+	Formats      []string       `json:"formats"`      // 输出格式，第一个为默认格式
+	Variants     []ImageVariant `json:"variants"`     // 按格式、宽度排序
+}
+
+// ValidateVariantSpec 校验并规范化宽度与格式列表
+func ValidateVariantSpec(spec *VariantSpec) error {
+	if len(spec.Widths) == 0 {
+		spec.Widths = DefaultVariantWidths
+	}
+	if len(spec.Widths) > maxVariantWidths {
+		return fmt.Errorf("%w: 最多生成 %d 个尺寸", ErrInvalidInput, maxVariantWidths)
+	}
+	for _, width := range spec.Widths {
+		if width <= 0 || width > maxVariantWidth {
+			return fmt.Errorf("%w: 宽度必须在 1-%d 之间", ErrInvalidInput, maxVariantWidth)
+		}
+	}
+
+	if len(spec.Formats) > maxVariantFormats {
+		return fmt.Errorf("%w: 最多生成 %d 种格式", ErrInvalidInput, maxVariantFormats)
+	}
+	formats := make([]string, 0, len(spec.Formats))
+	seen := make(map[string]bool)
+	for _, name := range spec.Formats {
+		format, err := NormalizeOutputFormat(name)
+		if err != nil {
+			return err
+		}
+		if !seen[format] {
+			seen[format] = true
+			formats = append(formats, format)
+		}
+	}
+	spec.Formats = formats
+	return nil
+}
+
+// GenerateVariants 从一次解码生成多个宽度与格式的压缩结果
+// 每个尺寸按普通压缩结果缓存，已存在的尺寸直接复用；结果宽度相同的尺寸只保留一个
+func (s *DefaultImageService) GenerateVariants(ctx context.Context, inputKey string, spec VariantSpec) (*VariantSet, error) {
+	if err := ValidateVariantSpec(&spec); err != nil {
+		return nil, err
+	}
+
+	source, err := s.loadSource(ctx, inputKey)
+	if err != nil {
+		return nil, err
+	}
+	load := func() (*sourceImage, error) { return source, nil }
+
+	formats := spec.Formats
+	if len(formats) == 0 {
+		format, ok := encodeFormatFor(source.format)
+		if !ok {
+			return nil, fmt.Errorf("不支持的图片格式: %s", source.format)
+		}
+		formats = []string{format}
+	}
+
+	widths := append([]int(nil), spec.Widths...)
+	sort.Ints(widths)
+
+	bounds := source.img.Bounds()
+	set := &VariantSet{
+		Width:   bounds.Dx(),
+		Height:  bounds.Dy(),
+		Formats: formats,
+	}
+	for _, format := range formats {
+		seen := make(map[int]bool)
+		for _, width := range widths {
+			options := spec.Options
+			options.Width = uint(width)
+			options.Height = 0
+			options.KeepAspect = true
+			options.Mode = ""
+			options.NoUpscale = true
+			options.OutputFormat = format
+
+			result, err := s.compressCachedWith(ctx, inputKey, options, load)
+			if err != nil {
+				return nil, err
+			}
+			set.SourceSHA256 = result.SourceSHA256
+			if seen[result.Width] {
+				continue
+			}
+			seen[result.Width] = true
+			set.Variants = append(set.Variants, ImageVariant{
+				Width:    result.Width,
+				Height:   result.Height,
+				Format:   format,
+				Filename: result.Filename,
+				URL:      s.compressed.URL(result.Filename),
+				Size:     result.CompressedSize,
+				Cached:   result.Cached,
+				Result:   result,
+				Options:  options,
+			})
+		}
+	}
+	return set, nil
+}
+
+// srcset 返回指定格式的 srcset 属性值
+func (set *VariantSet) srcset(format string) string {
+	parts := make([]string, 0, len(set.Variants))
+	for _, variant := range set.Variants {
+		if variant.Format == format {
+			parts = append(parts, fmt.Sprintf("%s %dw", variant.URL, variant.Width))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// HTML 生成可直接使用的 <img srcset> 片段，多种格式时生成 <picture>
+// 默认格式的最大尺寸作为 src
+func (set *VariantSet) HTML(alt, sizes string) string {
+	if len(set.Variants) == 0 {
+		return ""
+	}
+	if sizes == "" {
+		sizes = "100vw"
+	}
+
+	fallback := set.Formats[0]
+	var largest ImageVariant
+	for _, variant := range set.Variants {
+		if variant.Format == fallback && variant.Width >= largest.Width {
+			largest = variant
+		}
+	}
+	img := fmt.Sprintf(`<img src="%s" srcset="%s" sizes="%s" width="%d" height="%d" alt="%s" loading="lazy" decoding="async">`,
+		html.EscapeString(largest.URL), html.EscapeString(set.srcset(fallback)), html.EscapeString(sizes),
+		largest.Width, largest.Height, html.EscapeString(alt))
+	if len(set.Formats) == 1 {
+		return img
+	}
+
+	var b strings.Builder
+	b.WriteString("<picture>\n")
+	for _, format := range set.Formats[1:] {
+		fmt.Fprintf(&b, `  <source type="%s" srcset="%s" sizes="%s">`+"\n",
+			html.EscapeString("image/"+format), html.EscapeString(set.srcset(format)), html.EscapeString(sizes))
+	}
+	b.WriteString("  " + img + "\n")
+	b.WriteString("</picture>")
+	return b.String()
+}
@@ -0,0 +1,311 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"mini-toolbox/storage"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// 水印默认参数
+const (
+	defaultWatermarkGravity = "bottom-right"
+	defaultWatermarkOpacity = 0.5
+	defaultWatermarkScale   = 0.2
+	defaultWatermarkColor   = "#ffffff"
+
+	maxWatermarkText   = 100  // 水印文字的最大字符数
+	maxWatermarkMargin = 1000 // 水印与边缘的最大距离（像素）
+	watermarkTextPPEM  = 96   // 渲染文字时的字号（像素），之后再按比例缩放
+	minTiledScale      = 0.05 // 平铺时水印宽度占图片宽度的最小比例，每行最多 20 个
+	maxWatermarkTiles  = 2500 // 平铺时最多绘制的水印数
+)
+
+// WatermarkOption 水印参数，文字与图片二选一
+type WatermarkOption struct {
+	Text    string  `json:"text,omitempty"`    // 文字水印内容
+	Image   string  `json:"image,omitempty"`   // 图片水印在上传存储中的文件名（PNG）
+	Color   string  `json:"color,omitempty"`   // 文字颜色，默认 #ffffff
+	Gravity string  `json:"gravity,omitempty"` // 位置，取值同锚点，默认 bottom-right
+	Margin  int     `json:"margin,omitempty"`  // 与边缘及平铺时相互之间的距离（像素）
+	Opacity float64 `json:"opacity,omitempty"` // 不透明度 (0-1]，0 表示默认值 0.5
+	Scale   float64 `json:"scale,omitempty"`   // 水印宽度占图片宽度的比例 (0-1]，默认 0.2，平铺时不小于 0.05
+	Tiled   bool    `json:"tiled,omitempty"`   // 是否平铺整张图片
+}
+
+// ValidateWatermark 校验水印参数
+func ValidateWatermark(w *WatermarkOption) error {
+	if w == nil {
+		return nil
+	}
+	switch {
+	case w.Text == "" && w.Image == "":
+		return fmt.Errorf("%w: 水印需要文字或图片", ErrInvalidInput)
+	case w.Text != "" && w.Image != "":
+		return fmt.Errorf("%w: 水印文字与图片不能同时使用", ErrInvalidInput)
+	case w.Margin < 0 || w.Margin > maxWatermarkMargin:
+		return fmt.Errorf("%w: 水印边距必须在 0-%d 之间", ErrInvalidInput, maxWatermarkMargin)
+	case w.Opacity < 0 || w.Opacity > 1:
+		return fmt.Errorf("%w: 水印不透明度必须在 0-1 之间", ErrInvalidInput)
+	case w.Scale < 0 || w.Scale > 1:
+		return fmt.Errorf("%w: 水印比例必须在 0-1 之间", ErrInvalidInput)
+	case w.Tiled && w.Scale != 0 && w.Scale < minTiledScale:
+		return fmt.Errorf("%w: 平铺水印的比例不能小于 %g", ErrInvalidInput, minTiledScale)
+	}
+	if _, err := parseAnchor(w.Gravity); err != nil {
+		return err
+	}
+	if w.Image != "" {
+		if err := storage.ValidateKey(w.Image); err != nil {
+			return fmt.Errorf("%w: 无效的水印图片", ErrInvalidInput)
+		}
+		return nil
+	}
+	if w.Color != "" {
+		if _, err := ParseHexColor(w.Color); err != nil {
+			return err
+		}
+	}
+	return validateWatermarkText(w.Text)
+}
+
+// validateWatermarkText 检查文字长度，以及字体中是否包含所有字符
+func validateWatermarkText(text string) error {
+	if utf8.RuneCountInString(text) > maxWatermarkText {
+		return fmt.Errorf("%w: 水印文字最多 %d 个字符", ErrInvalidInput, maxWatermarkText)
+	}
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("%w: 水印文字不能为空", ErrInvalidInput)
+	}
+	f, err := watermarkFont()
+	if err != nil {
+		return err
+	}
+	var buf sfnt.Buffer
+	for _, r := range text {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("%w: 水印文字不能包含控制字符", ErrInvalidInput)
+		}
+		if index, err := f.GlyphIndex(&buf, r); err != nil || (index == 0 && r != ' ') {
+			return fmt.Errorf("%w: 水印字体不支持字符 %q", ErrInvalidInput, r)
+		}
+	}
+	return nil
+}
+
+// normalized 返回填充默认值后的水印参数
+func (w WatermarkOption) normalized() WatermarkOption {
+	w.Gravity = strings.ToLower(strings.TrimSpace(w.Gravity))
+	if w.Gravity == "" {
+		w.Gravity = defaultWatermarkGravity
+	}
+	if w.Tiled {
+		w.Gravity = ""
+	}
+	if w.Opacity == 0 {
+		w.Opacity = defaultWatermarkOpacity
+	}
+	if w.Scale == 0 {
+		w.Scale = defaultWatermarkScale
+	}
+	if w.Image != "" {
+		w.Color = ""
+	} else {
+		if w.Color == "" {
+			w.Color = defaultWatermarkColor
+		}
+		if c, err := ParseHexColor(w.Color); err == nil {
+			w.Color = fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+		}
+	}
+	return w
+}
+
+// watermarkMark 生成水印图案：渲染文字，或从上传存储读取水印图片
+func (s *DefaultImageService) watermarkMark(ctx context.Context, w WatermarkOption) (image.Image, error) {
+	if w.Text != "" {
+		textColor, err := ParseHexColor(w.Color)
+		if err != nil {
+			return nil, err
+		}
+		return renderWatermarkText(w.Text, textColor)
+	}
+
+	data, err := storage.ReadAll(ctx, s.uploads, w.Image)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%w: 水印图片不存在", ErrInvalidInput)
+		}
+		return nil, fmt.Errorf("无法读取水印图片: %v", err)
+	}
+	mark, _, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	return mark, nil
+}
+
+// applyWatermark 按位置或平铺方式将水印叠加到图片上
+// 平铺的水印数超过 maxWatermarkTiles 时返回错误，如很扁的水印图片在高图上平铺
+func applyWatermark(img, mark image.Image, w WatermarkOption) (image.Image, error) {
+	bounds := img.Bounds()
+	markBounds := mark.Bounds()
+	if bounds.Empty() || markBounds.Empty() {
+		return img, nil
+	}
+
+	// 按图片宽度缩放水印，并保证不超出图片高度
+	width := max(int(math.Round(float64(bounds.Dx())*w.Scale)), 1)
+	height := max(int(math.Round(float64(markBounds.Dy())*float64(width)/float64(markBounds.Dx()))), 1)
+	if height > bounds.Dy() {
+		height = bounds.Dy()
+		width = max(int(math.Round(float64(markBounds.Dx())*float64(height)/float64(markBounds.Dy()))), 1)
+	}
+	if w.Tiled {
+		cols := (bounds.Dx() - w.Margin + width + w.Margin - 1) / (width + w.Margin)
+		rows := (bounds.Dy() - w.Margin + height + w.Margin - 1) / (height + w.Margin)
+		if cols*rows > maxWatermarkTiles {
+			return nil, fmt.Errorf("%w: 平铺水印数量 %d 超过 %d，请增大比例或边距", ErrInvalidInput, cols*rows, maxWatermarkTiles)
+		}
+	}
+	scaled := imaging.Resize(mark, width, height, imaging.Lanczos)
+
+	dst := imaging.Clone(img)
+	mask := image.NewUniform(color.Alpha{A: uint8(math.Round(w.Opacity * 255))})
+	size := image.Pt(width, height)
+	stamp := func(p image.Point) {
+		draw.DrawMask(dst, image.Rectangle{Min: p, Max: p.Add(size)}, scaled, image.Point{}, mask, image.Point{}, draw.Over)
+	}
+
+	if w.Tiled {
+		for y := w.Margin; y < dst.Bounds().Dy(); y += height + w.Margin {
+			for x := w.Margin; x < dst.Bounds().Dx(); x += width + w.Margin {
+				stamp(image.Pt(x, y))
+			}
+		}
+		return dst, nil
+	}
+
+	anchor, _ := parseAnchor(w.Gravity)
+	p := anchorPoint(anchor, dst.Bounds().Dx()-2*w.Margin, dst.Bounds().Dy()-2*w.Margin, width, height)
+	stamp(p.Add(image.Pt(w.Margin, w.Margin)))
+	return dst, nil
+}
+
+var (
+	watermarkFontOnce sync.Once
+	watermarkFontData *sfnt.Font
+	watermarkFontErr  error
+)
+
+// watermarkFont 返回渲染水印文字使用的 Go Bold 字体
+func watermarkFont() (*sfnt.Font, error) {
+	watermarkFontOnce.Do(func() {
+		watermarkFontData, watermarkFontErr = sfnt.Parse(gobold.TTF)
+	})
+	return watermarkFontData, watermarkFontErr
+}
+
+// renderWatermarkText 将单行文字渲染为透明背景的图片
+// 文字下方带有半透明阴影，以便在浅色背景上辨认
+func renderWatermarkText(text string, textColor color.NRGBA) (image.Image, error) {
+	f, err := watermarkFont()
+	if err != nil {
+		return nil, err
+	}
+	var buf sfnt.Buffer
+	ppem := fixed.I(watermarkTextPPEM)
+	metrics, err := f.Metrics(&buf, ppem, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// 计算每个字符的位置与总宽度
+	type glyph struct {
+		index sfnt.GlyphIndex
+		x     fixed.Int26_6
+	}
+	glyphs := make([]glyph, 0, len(text))
+	var x fixed.Int26_6
+	var prev sfnt.GlyphIndex
+	for i, r := range []rune(text) {
+		index, err := f.GlyphIndex(&buf, r)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			if kern, err := f.Kern(&buf, prev, index, ppem, 0); err == nil {
+				x += kern
+			}
+		}
+		glyphs = append(glyphs, glyph{index: index, x: x})
+		advance, err := f.GlyphAdvance(&buf, index, ppem, 0)
+		if err != nil {
+			return nil, err
+		}
+		x += advance
+		prev = index
+	}
+
+	shadow := watermarkTextPPEM / 24
+	padding := watermarkTextPPEM / 8
+	width := x.Ceil() + 2*padding + shadow
+	height := (metrics.Ascent + metrics.Descent).Ceil() + 2*padding + shadow
+	originX := float32(padding)
+	originY := float32(padding) + float32(metrics.Ascent)/64
+
+	rasterizer := vector.NewRasterizer(width, height)
+	for _, g := range glyphs {
+		segments, err := f.LoadGlyph(&buf, g.index, ppem, nil)
+		if err != nil {
+			return nil, err
+		}
+		gx := originX + float32(g.x)/64
+		for _, seg := range segments {
+			args := seg.Args
+			switch seg.Op {
+			case sfnt.SegmentOpMoveTo:
+				rasterizer.ClosePath()
+				rasterizer.MoveTo(gx+float32(args[0].X)/64, originY+float32(args[0].Y)/64)
+			case sfnt.SegmentOpLineTo:
+				rasterizer.LineTo(gx+float32(args[0].X)/64, originY+float32(args[0].Y)/64)
+			case sfnt.SegmentOpQuadTo:
+				rasterizer.QuadTo(
+					gx+float32(args[0].X)/64, originY+float32(args[0].Y)/64,
+					gx+float32(args[1].X)/64, originY+float32(args[1].Y)/64,
+				)
+			case sfnt.SegmentOpCubeTo:
+				rasterizer.CubeTo(
+					gx+float32(args[0].X)/64, originY+float32(args[0].Y)/64,
+					gx+float32(args[1].X)/64, originY+float32(args[1].Y)/64,
+					gx+float32(args[2].X)/64, originY+float32(args[2].Y)/64,
+				)
+			}
+		}
+	}
+	rasterizer.ClosePath()
+
+	textMask := image.NewAlpha(image.Rect(0, 0, width, height))
+	rasterizer.Draw(textMask, textMask.Bounds(), image.Opaque, image.Point{})
+
+	out := image.NewNRGBA(textMask.Bounds())
+	shadowColor := image.NewUniform(color.NRGBA{A: textColor.A / 2})
+	draw.DrawMask(out, out.Bounds().Add(image.Pt(shadow, shadow)), shadowColor, image.Point{}, textMask, image.Point{}, draw.Over)
+	draw.DrawMask(out, out.Bounds(), image.NewUniform(textColor), image.Point{}, textMask, image.Point{}, draw.Over)
+	return out, nil
+}
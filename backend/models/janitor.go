@@ -0,0 +1,270 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"mini-toolbox/storage"
+)
+
+// 清理区域
+const (
+	AreaUploads    = "uploads"    // 原始上传文件
+	AreaCompressed = "compressed" // 压缩结果
+	AreaCache      = "cache"      // 按需处理的输出缓存
+)
+
+// RetentionPolicy 文件保留策略，时长或大小为 0 表示不限制
+type RetentionPolicy struct {
+	UploadTTL     time.Duration // 原始上传文件的保留时间
+	CompressedTTL time.Duration // 压缩结果的保留时间
+	MaxBytes      int64         // 所有区域合计的最大字节数，超出时按最近最少使用淘汰
+	Interval      time.Duration // 自动清理间隔，0 表示只能手动触发
+}
+
+// SweepReport 一次清理的结果
+type SweepReport struct {
+	StartedAt  time.Time     `json:"startedAt"`
+	Duration   time.Duration `json:"duration"`
+	Expired    int           `json:"expired"`    // 过期删除的文件数
+	Evicted    int           `json:"evicted"`    // 超出容量淘汰的文件数
+	FreedBytes int64         `json:"freedBytes"` // 释放的字节数
+	TotalBytes int64         `json:"totalBytes"` // 清理后剩余的字节数
+	Errors     []string      `json:"errors,omitempty"`
+}
+
+// sweepItem 清理时的候选文件
+type sweepItem struct {
+	area      string
+	object    storage.ObjectInfo
+	expiresAt time.Time // 零值表示不过期
+	lastUsed  time.Time
+}
+
+// janitorArea 清理区域及其保留时间
+type janitorArea struct {
+	name  string
+	store storage.Storage
+	ttl   time.Duration
+}
+
+// Janitor 按保留策略定期清理上传文件、压缩结果以及通过 AddArea 登记的其他区域
+type Janitor struct {
+	policy  RetentionPolicy
+	areas   []janitorArea
+	catalog ImageCatalog
+
+	sweepMu sync.Mutex // 同一时间只执行一次清理
+
+	mu     sync.Mutex
+	access map[string]time.Time // 区域/文件名 -> 最近访问时间
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewJanitor 创建清理任务，调用 Start 后开始定期清理
+func NewJanitor(policy RetentionPolicy, uploads, compressed storage.Storage, catalog ImageCatalog) *Janitor {
+	return &Janitor{
+		policy: policy,
+		areas: []janitorArea{
+			{name: AreaUploads, store: uploads, ttl: policy.UploadTTL},
+			{name: AreaCompressed, store: compressed, ttl: policy.CompressedTTL},
+		},
+		catalog: catalog,
+		access:  make(map[string]time.Time),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// AddArea 登记需要清理的其他区域，ttl 为 0 表示只参与容量淘汰，必须在 Start 之前调用
+func (j *Janitor) AddArea(name string, store storage.Storage, ttl time.Duration) {
+	j.areas = append(j.areas, janitorArea{name: name, store: store, ttl: ttl})
+}
+
+// Policy 返回保留策略
+func (j *Janitor) Policy() RetentionPolicy {
+	return j.policy
+}
+
+// Start 启动定期清理协程
+func (j *Janitor) Start() {
+	if j.policy.Interval <= 0 {
+		close(j.done)
+		return
+	}
+	go func() {
+		defer close(j.done)
+		ticker := time.NewTicker(j.policy.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-j.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithCancel(context.Background())
+				go func() {
+					// 停止时中断正在进行的清理
+					select {
+					case <-j.stop:
+						cancel()
+					case <-ctx.Done():
+					}
+				}()
+				if report, err := j.Sweep(ctx); err != nil {
+					log.Printf("清理文件失败: %v", err)
+				} else if report.Expired+report.Evicted > 0 {
+					log.Printf("清理文件: 过期 %d 个，淘汰 %d 个，释放 %d 字节", report.Expired, report.Evicted, report.FreedBytes)
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+// Stop 停止定期清理并等待正在进行的清理结束，可重复调用
+// 必须在 Start 之后调用
+func (j *Janitor) Stop() {
+	j.stopOnce.Do(func() { close(j.stop) })
+	<-j.done
+}
+
+// Touch 记录文件被访问，用于按最近最少使用淘汰
+func (j *Janitor) Touch(area, key string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.access[area+"/"+key] = time.Now()
+}
+
+// Sweep 立即执行一次清理：先删除过期文件，再在超出容量时按最近最少使用淘汰
+func (j *Janitor) Sweep(ctx context.Context) (*SweepReport, error) {
+	j.sweepMu.Lock()
+	defer j.sweepMu.Unlock()
+
+	report := &SweepReport{StartedAt: time.Now()}
+	items, err := j.collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	remaining := items[:0]
+	for _, item := range items {
+		if !item.expiresAt.IsZero() && now.After(item.expiresAt) {
+			if err := j.remove(ctx, item, report); err == nil {
+				report.Expired++
+				continue
+			}
+		}
+		remaining = append(remaining, item)
+	}
+	// 文件仍被其他上传引用时只移除已过期的上传记录
+	j.catalog.PruneUploads(now)
+
+	var total int64
+	for _, item := range remaining {
+		total += item.object.Size
+	}
+	if j.policy.MaxBytes > 0 && total > j.policy.MaxBytes {
+		sort.Slice(remaining, func(a, b int) bool {
+			return remaining[a].lastUsed.Before(remaining[b].lastUsed)
+		})
+		for _, item := range remaining {
+			if total <= j.policy.MaxBytes {
+				break
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if err := j.remove(ctx, item, report); err == nil {
+				report.Evicted++
+				total -= item.object.Size
+			}
+		}
+	}
+
+	report.TotalBytes = total
+	report.Duration = time.Since(report.StartedAt)
+	return report, nil
+}
+
+// collect 列出所有区域中的文件并计算过期时间与最近使用时间
+func (j *Janitor) collect(ctx context.Context) ([]sweepItem, error) {
+	listed := make([][]storage.ObjectInfo, len(j.areas))
+	for i, area := range j.areas {
+		objects, err := area.store.List(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		listed[i] = objects
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var items []sweepItem
+	for i, area := range j.areas {
+		for _, object := range listed[i] {
+			item := j.newItemLocked(area.name, object, area.ttl)
+			switch area.name {
+			case AreaUploads:
+				if expiresAt := j.catalog.UploadExpiry(object.Key); expiresAt != nil {
+					item.expiresAt = *expiresAt
+				}
+			case AreaCompressed:
+				if expiresAt := j.catalog.FileExpiry(object.Key); expiresAt != nil {
+					item.expiresAt = *expiresAt
+				}
+			}
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// newItemLocked 创建候选文件，默认保留时间从最近一次使用开始计算，调用方需持有 j.mu
+func (j *Janitor) newItemLocked(area string, object storage.ObjectInfo, ttl time.Duration) sweepItem {
+	item := sweepItem{area: area, object: object, lastUsed: object.ModTime}
+	if t, ok := j.access[area+"/"+object.Key]; ok && t.After(item.lastUsed) {
+		item.lastUsed = t
+	}
+	if ttl > 0 {
+		item.expiresAt = item.lastUsed.Add(ttl)
+	}
+	return item
+}
+
+// remove 删除文件及其目录记录
+func (j *Janitor) remove(ctx context.Context, item sweepItem, report *SweepReport) error {
+	var store storage.Storage
+	for _, area := range j.areas {
+		if area.name == item.area {
+			store = area.store
+		}
+	}
+	if err := store.Delete(ctx, item.object.Key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		report.Errors = append(report.Errors, item.area+"/"+item.object.Key+": "+err.Error())
+		return err
+	}
+	if item.area == AreaCompressed {
+		if err := j.catalog.DeleteFile(item.object.Key); err != nil && !errors.Is(err, ErrRecordNotFound) {
+			report.Errors = append(report.Errors, item.area+"/"+item.object.Key+": "+err.Error())
+		}
+	}
+
+	if item.area == AreaUploads {
+		j.catalog.DeleteUploadFile(item.object.Key)
+	}
+
+	j.mu.Lock()
+	delete(j.access, item.area+"/"+item.object.Key)
+	j.mu.Unlock()
+
+	report.FreedBytes += item.object.Size
+	return nil
+}
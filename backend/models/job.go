@@ -0,0 +1,459 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// JobState 任务状态
+type JobState string
+
+// 任务状态
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobDone      JobState = "done"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+// 任务相关错误
+var (
+	ErrJobNotFound  = errors.New("任务不存在")
+	ErrJobFinished  = errors.New("任务已结束")
+	ErrQueueFull    = errors.New("任务队列已满，请稍后重试")
+	ErrQueueStopped = errors.New("任务队列已停止")
+)
+
+// jobRetention 已结束任务的保留时间
+const jobRetention = time.Hour
+
+// JobFunc 任务执行函数，需要在 ctx 取消后尽快返回
+type JobFunc func(ctx context.Context) (interface{}, error)
+
+// Job 异步任务
+type Job struct {
+	ID         string      `json:"id"`
+	BatchID    string      `json:"batchId,omitempty"`
+	Type       string      `json:"type"`
+	Filename   string      `json:"filename,omitempty"`
+	State      JobState    `json:"state"`
+	Stage      string      `json:"stage"`
+	Progress   int         `json:"progress"`
+	Error      string      `json:"error,omitempty"`
+	Result     interface{} `json:"result,omitempty"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	StartedAt  *time.Time  `json:"startedAt,omitempty"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+
+	task     JobFunc
+	ctx      context.Context
+	cancel   context.CancelFunc
+	onCancel func()
+}
+
+// finished 任务是否已结束
+func (j *Job) finished() bool {
+	return j.State == JobDone || j.State == JobFailed || j.State == JobCancelled
+}
+
+// snapshot 返回任务的只读副本
+func (j *Job) snapshot() Job {
+	return Job{
+		ID:         j.ID,
+		BatchID:    j.BatchID,
+		Type:       j.Type,
+		Filename:   j.Filename,
+		State:      j.State,
+		Stage:      j.Stage,
+		Progress:   j.Progress,
+		Error:      j.Error,
+		Result:     j.Result,
+		CreatedAt:  j.CreatedAt,
+		StartedAt:  j.StartedAt,
+		FinishedAt: j.FinishedAt,
+	}
+}
+
+// event 根据任务当前状态生成事件
+func (j *Job) event() JobEvent {
+	event := JobEvent{
+		JobID:    j.ID,
+		BatchID:  j.BatchID,
+		Filename: j.Filename,
+		Stage:    j.Stage,
+		Percent:  j.Progress,
+		State:    j.State,
+		Error:    j.Error,
+		Time:     time.Now(),
+	}
+	if j.State == JobDone {
+		event.Result = j.Result
+	}
+	return event
+}
+
+// JobEvent 任务进度事件
+type JobEvent struct {
+	JobID    string      `json:"jobId"`
+	BatchID  string      `json:"batchId,omitempty"`
+	Filename string      `json:"filename,omitempty"`
+	Stage    string      `json:"stage"`
+	Percent  int         `json:"percent"`
+	State    JobState    `json:"state"`
+	Error    string      `json:"error,omitempty"`
+	Result   interface{} `json:"result,omitempty"`
+	Time     time.Time   `json:"time"`
+}
+
+// Finished 事件是否表示任务已结束
+func (e JobEvent) Finished() bool {
+	return e.State == JobDone || e.State == JobFailed || e.State == JobCancelled
+}
+
+// subscriberBuffer 订阅者事件缓冲区大小，缓冲区满时丢弃事件
+const subscriberBuffer = 64
+
+// jobSubscriber 事件订阅者
+type jobSubscriber struct {
+	id string // 订阅的任务 ID 或批次 ID
+	ch chan JobEvent
+}
+
+// JobSpec 提交任务时的描述
+type JobSpec struct {
+	ID       string
+	BatchID  string
+	Type     string
+	Filename string
+	Task     JobFunc
+	OnCancel func() // 任务被取消后的清理函数，释放队列锁后调用，可为空
+}
+
+// JobQueue 固定数量工作协程的任务队列
+type JobQueue struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	pending chan *Job
+	ctx     context.Context
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+	closed  bool
+	workers int
+
+	subscribers map[*jobSubscriber]struct{}
+}
+
+// NewJobQueue 创建任务队列，workers 为并发数，capacity 为排队上限
+func NewJobQueue(workers, capacity int) *JobQueue {
+	if workers < 1 {
+		workers = 1
+	}
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	q := &JobQueue{
+		jobs:        make(map[string]*Job),
+		pending:     make(chan *Job, capacity),
+		ctx:         ctx,
+		stop:        stop,
+		workers:     workers,
+		subscribers: make(map[*jobSubscriber]struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+	return q
+}
+
+// Workers 返回并发数
+func (q *JobQueue) Workers() int {
+	return q.workers
+}
+
+// Submit 提交任务，队列已满时返回 ErrQueueFull
+func (q *JobQueue) Submit(spec JobSpec) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return Job{}, ErrQueueStopped
+	}
+	q.pruneLocked()
+
+	ctx, cancel := context.WithCancel(q.ctx)
+	job := &Job{
+		ID:        spec.ID,
+		BatchID:   spec.BatchID,
+		Type:      spec.Type,
+		Filename:  spec.Filename,
+		State:     JobQueued,
+		Stage:     StageUploadReceived,
+		CreatedAt: time.Now(),
+		task:      spec.Task,
+		ctx:       ctx,
+		cancel:    cancel,
+		onCancel:  spec.OnCancel,
+	}
+
+	select {
+	case q.pending <- job:
+	default:
+		cancel()
+		return Job{}, ErrQueueFull
+	}
+	q.jobs[job.ID] = job
+	q.publishLocked(job)
+	return job.snapshot(), nil
+}
+
+// Get 获取任务状态
+func (q *JobQueue) Get(id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	return job.snapshot(), nil
+}
+
+// List 列出任务，batchID 不为空时只返回该批次的任务
+func (q *JobQueue) List(batchID string) []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		if batchID == "" || job.BatchID == batchID {
+			jobs = append(jobs, job.snapshot())
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// Subscribe 订阅任务或批次的进度事件，返回事件通道与取消订阅函数
+// 订阅前已发生的事件不会补发，调用方应先通过 Snapshot 获取当前状态
+func (q *JobQueue) Subscribe(id string) (<-chan JobEvent, func(), error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.matchLocked(id)) == 0 {
+		return nil, nil, ErrJobNotFound
+	}
+	sub := &jobSubscriber{id: id, ch: make(chan JobEvent, subscriberBuffer)}
+	q.subscribers[sub] = struct{}{}
+
+	unsubscribe := func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if _, ok := q.subscribers[sub]; ok {
+			delete(q.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+	return sub.ch, unsubscribe, nil
+}
+
+// Snapshot 返回任务或批次中所有任务的当前状态事件
+func (q *JobQueue) Snapshot(id string) []JobEvent {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := q.matchLocked(id)
+	events := make([]JobEvent, 0, len(jobs))
+	for _, job := range jobs {
+		events = append(events, job.event())
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].JobID < events[j].JobID
+	})
+	return events
+}
+
+// matchLocked 查找 ID 对应的任务或批次中的任务，调用方需持有锁
+func (q *JobQueue) matchLocked(id string) []*Job {
+	if job, ok := q.jobs[id]; ok {
+		return []*Job{job}
+	}
+	var jobs []*Job
+	if id == "" {
+		return jobs
+	}
+	for _, job := range q.jobs {
+		if job.BatchID == id {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+// publishLocked 向订阅者发送任务当前状态，调用方需持有锁
+func (q *JobQueue) publishLocked(job *Job) {
+	if len(q.subscribers) == 0 {
+		return
+	}
+	event := job.event()
+	for sub := range q.subscribers {
+		if sub.id != job.ID && sub.id != job.BatchID {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+		}
+	}
+}
+
+// reporter 返回更新任务进度的回调
+func (q *JobQueue) reporter(job *Job) ProgressFunc {
+	return func(stage string, percent int) {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if job.finished() {
+			return
+		}
+		job.Stage = stage
+		job.Progress = percent
+		q.publishLocked(job)
+	}
+}
+
+// Cancel 取消任务，排队中的任务直接取消，运行中的任务在下一个检查点停止
+func (q *JobQueue) Cancel(id string) (Job, error) {
+	q.mu.Lock()
+	job, ok := q.jobs[id]
+	if !ok {
+		q.mu.Unlock()
+		return Job{}, ErrJobNotFound
+	}
+	if job.finished() {
+		snapshot := job.snapshot()
+		q.mu.Unlock()
+		return snapshot, ErrJobFinished
+	}
+
+	job.cancel()
+	var onCancel func()
+	if job.State == JobQueued {
+		onCancel = q.finishLocked(job, JobCancelled, nil, context.Canceled)
+	}
+	snapshot := job.snapshot()
+	q.mu.Unlock()
+
+	runCallback(onCancel)
+	return snapshot, nil
+}
+
+// Close 停止接收新任务，取消未完成的任务，结束所有订阅并等待工作协程退出
+func (q *JobQueue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	q.stop()
+	close(q.pending)
+	for sub := range q.subscribers {
+		delete(q.subscribers, sub)
+		close(sub.ch)
+	}
+	q.mu.Unlock()
+
+	q.wg.Wait()
+}
+
+// worker 工作协程
+func (q *JobQueue) worker() {
+	defer q.wg.Done()
+	for job := range q.pending {
+		q.run(job)
+	}
+}
+
+// run 执行单个任务
+func (q *JobQueue) run(job *Job) {
+	q.mu.Lock()
+	if job.finished() {
+		q.mu.Unlock()
+		return
+	}
+	if job.ctx.Err() != nil {
+		onCancel := q.finishLocked(job, JobCancelled, nil, job.ctx.Err())
+		q.mu.Unlock()
+		runCallback(onCancel)
+		return
+	}
+	now := time.Now()
+	job.State = JobRunning
+	job.StartedAt = &now
+	q.mu.Unlock()
+
+	result, err := job.task(WithProgress(job.ctx, q.reporter(job)))
+
+	var onCancel func()
+	q.mu.Lock()
+	switch {
+	case job.ctx.Err() != nil:
+		onCancel = q.finishLocked(job, JobCancelled, nil, job.ctx.Err())
+	case err != nil:
+		onCancel = q.finishLocked(job, JobFailed, nil, err)
+	default:
+		onCancel = q.finishLocked(job, JobDone, result, nil)
+	}
+	q.mu.Unlock()
+	runCallback(onCancel)
+}
+
+// finishLocked 结束任务，调用方需持有锁
+// 任务被取消时返回其取消回调，回调可能访问存储，调用方需在释放锁后执行
+func (q *JobQueue) finishLocked(job *Job, state JobState, result interface{}, err error) func() {
+	now := time.Now()
+	job.State = state
+	job.Result = result
+	job.FinishedAt = &now
+	switch state {
+	case JobDone:
+		job.Stage, job.Progress = StageDone, 100
+	case JobFailed:
+		job.Stage = StageFailed
+		job.Error = err.Error()
+	case JobCancelled:
+		job.Stage = StageCancelled
+		job.Error = "任务已取消"
+	}
+	job.cancel()
+	q.publishLocked(job)
+	if state == JobCancelled {
+		return job.onCancel
+	}
+	return nil
+}
+
+// runCallback 执行可能为空的回调
+func runCallback(callback func()) {
+	if callback != nil {
+		callback()
+	}
+}
+
+// pruneLocked 清理超过保留时间的已结束任务，调用方需持有锁
+func (q *JobQueue) pruneLocked() {
+	cutoff := time.Now().Add(-jobRetention)
+	for id, job := range q.jobs {
+		if job.finished() && job.FinishedAt.Before(cutoff) {
+			delete(q.jobs, id)
+		}
+	}
+}
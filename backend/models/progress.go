@@ -0,0 +1,32 @@
+package models
+
+import "context"
+
+// 处理进度阶段
+const (
+	StageUploadReceived = "upload-received"
+	StageDecoding       = "decoding"
+	StageResizing       = "resizing"
+	StageEncoding       = "encoding"
+	StageDone           = "done"
+	StageFailed         = "failed"
+	StageCancelled      = "cancelled"
+)
+
+// ProgressFunc 进度回调，percent 取值 0-100
+type ProgressFunc func(stage string, percent int)
+
+// progressKey 进度回调在 context 中的键
+type progressKey struct{}
+
+// WithProgress 返回携带进度回调的 context
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// reportProgress 向 context 中的进度回调报告进度，未设置回调时忽略
+func reportProgress(ctx context.Context, stage string, percent int) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok && fn != nil {
+		fn(stage, percent)
+	}
+}
@@ -3,13 +3,28 @@ package routes
 import (
 	"mini-toolbox/handlers"
 	"mini-toolbox/models"
-	"os"
+	"mini-toolbox/storage"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Services 路由依赖的服务
+type Services struct {
+	Users      models.UserService
+	Jobs       *models.JobQueue
+	Catalog    models.ImageCatalog // 压缩结果目录
+	Janitor    *models.Janitor     // 过期文件清理任务
+	AdminToken string              // 管理接口令牌，为空时禁用管理接口
+
+	TransformCache       storage.Storage           // 按需处理结果缓存
+	TransformPolicy      models.URLTransformPolicy // 按需处理的参数限制
+	TransformConcurrency int                       // 同时按需处理的请求数
+	Uploads              storage.Storage           // 原始上传文件存储
+	Compressed           storage.Storage           // 压缩结果存储
+}
+
 // SetupRoutes 设置应用程序路由
-func SetupRoutes(userService models.UserService) *gin.Engine {
+func SetupRoutes(services Services) *gin.Engine {
 	// 创建 Gin 路由器
 	r := gin.Default()
 
@@ -19,18 +34,14 @@ func SetupRoutes(userService models.UserService) *gin.Engine {
 
 	// 创建处理器
 	appHandler := handlers.NewAppHandler()
-	userHandler := handlers.NewUserHandler(userService)
+	userHandler := handlers.NewUserHandler(services.Users)
+	jobHandler := handlers.NewJobHandler(services.Jobs)
+	adminHandler := handlers.NewAdminHandler(services.Janitor, services.AdminToken)
 
 	// 创建图片服务和处理器
-	uploadDir := "uploads"
-	compressedDir := "compressed"
-
-	// 确保目录存在
-	os.MkdirAll(uploadDir, 0755)
-	os.MkdirAll(compressedDir, 0755)
-
-	imageService := models.NewDefaultImageService(uploadDir, compressedDir)
-	imageHandler := handlers.NewImageHandler(imageService, uploadDir, compressedDir)
+	imageService := models.NewDefaultImageService(services.Uploads, services.Compressed)
+	transformHandler := handlers.NewTransformHandler(services.Uploads, services.TransformCache, services.TransformPolicy, services.Catalog, services.Janitor, services.TransformConcurrency)
+	imageHandler := handlers.NewImageHandler(imageService, services.Jobs, services.Catalog, services.Janitor, services.Uploads, services.Compressed)
 
 	// 基本路由
 	r.GET("/", appHandler.HomePage)
@@ -41,8 +52,11 @@ func SetupRoutes(userService models.UserService) *gin.Engine {
 	r.GET("/images", imageHandler.ListCompressedImages) // 兼容原有前端调用
 
 	// 提供静态文件访问
-	r.Static("/static", uploadDir)
-	r.Static("/compressed", compressedDir)
+	r.GET("/static/*filepath", imageHandler.ServeUpload)
+	r.GET("/compressed/*filepath", imageHandler.ServeCompressed)
+
+	// 按 URL 参数按需处理图片，如 /img/w_400,h_300,q_70,fit_fill/<id>.jpg
+	r.GET("/img/:params/:file", transformHandler.Serve)
 
 	// API 路由组
 	api := r.Group("/api")
@@ -53,8 +67,8 @@ func SetupRoutes(userService models.UserService) *gin.Engine {
 		api.POST("/upload-compress", imageHandler.UploadAndCompress) // 上传并压缩
 
 		// 为前端兼容性提供静态文件访问
-		api.Static("/static", compressedDir) // 前端期望通过 /api/static/ 访问压缩后的图片
-		api.Static("/uploads", uploadDir)    // 访问原始上传文件
+		api.GET("/static/*filepath", imageHandler.ServeCompressed) // 前端期望通过 /api/static/ 访问压缩后的图片
+		api.GET("/uploads/*filepath", imageHandler.ServeUpload)    // 访问原始上传文件
 	}
 
 	// API v1 路由组
@@ -72,10 +86,35 @@ func SetupRoutes(userService models.UserService) *gin.Engine {
 		images := apiV1.Group("/images")
 		{
 			images.POST("/compress", imageHandler.UploadAndCompress)           // 上传并压缩图片
+			images.POST("/batch", imageHandler.BatchCompress)                  // 批量上传并压缩图片，返回 ZIP
+			images.POST("/variants", imageHandler.GenerateVariants)            // 生成响应式图片的多个尺寸
 			images.GET("/formats", imageHandler.GetSupportedFormats)           // 获取支持的格式
 			images.GET("/list", imageHandler.ListCompressedImages)             // 列出所有压缩图片
 			images.GET("/download/:filename", imageHandler.DownloadCompressed) // 下载压缩图片
 			images.DELETE("/:filename", imageHandler.DeleteCompressedImage)    // 删除压缩图片
+			images.GET("/:filename/info", imageHandler.GetImageInfo)           // 获取已存储图片的元信息
+			images.GET("/:filename/palette", imageHandler.GetImagePalette)     // 提取已存储图片的主色
+			images.POST("/info", imageHandler.InspectUpload)                   // 获取上传图片的元信息
+			images.POST("/similar", imageHandler.FindSimilarImages)            // 查找相似图片
+			images.POST("/compare", imageHandler.CompareImages)                // 比较两张图片
+			images.POST("/diff", imageHandler.DiffImages)                      // 生成两张图片的差异热力图
+		}
+
+		// 异步任务相关路由
+		jobs := apiV1.Group("/jobs")
+		{
+			jobs.POST("/compress", imageHandler.SubmitCompressJobs) // 上传图片并提交异步压缩任务
+			jobs.GET("", jobHandler.ListJobs)                       // 列出任务
+			jobs.GET("/:id", jobHandler.GetJob)                     // 查询任务状态
+			jobs.DELETE("/:id", jobHandler.CancelJob)               // 取消任务
+			jobs.GET("/:id/events", jobHandler.StreamJobEvents)     // 以 SSE 推送任务或批次进度
+		}
+
+		// 管理路由，需要 X-Admin-Token 请求头
+		admin := apiV1.Group("/admin", adminHandler.RequireToken)
+		{
+			admin.GET("/retention", adminHandler.GetRetention) // 查看文件保留策略
+			admin.POST("/sweep", adminHandler.Sweep)           // 立即清理过期文件
 		}
 	}
 
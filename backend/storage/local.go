@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage 本地文件系统存储
+type LocalStorage struct {
+	dir       string
+	urlPrefix string
+}
+
+// NewLocalStorage 创建本地文件系统存储，dir 不存在时自动创建
+func NewLocalStorage(dir, urlPrefix string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{
+		dir:       dir,
+		urlPrefix: urlPrefix,
+	}, nil
+}
+
+// path 返回对象键对应的文件路径
+func (s *LocalStorage) path(key string) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	// 再次确认路径位于存储目录内
+	filePath := filepath.Join(s.dir, key)
+	if filepath.Dir(filePath) != filepath.Clean(s.dir) {
+		return "", ErrInvalidKey
+	}
+	return filePath, nil
+}
+
+// Put 写入对象，先写临时文件再重命名，避免读到不完整的文件
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	filePath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filePath)
+}
+
+// Get 读取对象
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	filePath, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+// GetRange 读取从 offset 开始的 length 个字节
+func (s *LocalStorage) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 || length < 0 {
+		return nil, errors.New("无效的读取范围")
+	}
+	reader, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	file := reader.(*os.File)
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return limitedReadCloser{io.LimitReader(file, length), file}, nil
+}
+
+// limitedReadCloser 限制读取长度并关闭底层文件
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Stat 获取对象信息
+func (s *LocalStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	filePath, err := s.path(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	info, err := os.Stat(filePath)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+		return ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return fileObjectInfo(info), nil
+}
+
+// List 列出键以 prefix 开头的对象
+func (s *LocalStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]ObjectInfo, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".tmp-") || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, fileObjectInfo(info))
+	}
+	return objects, nil
+}
+
+// Delete 删除对象
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	filePath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// URL 返回对象的访问地址
+func (s *LocalStorage) URL(key string) string {
+	return joinURL(s.urlPrefix, key)
+}
+
+// fileObjectInfo 将文件信息转换为对象信息
+func fileObjectInfo(info fs.FileInfo) ObjectInfo {
+	return ObjectInfo{
+		Key:         info.Name(),
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		ContentType: mime.TypeByExtension(filepath.Ext(info.Name())),
+	}
+}
@@ -0,0 +1,462 @@
+package storage
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testBucket    = "bucket"
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "us-east-1"
+	testPageSize  = 2
+)
+
+// fakeS3 基于 httptest 的最小 S3 服务，校验 SigV4 签名并支持对象读写、范围读取与分页列表
+type fakeS3 struct {
+	t *testing.T
+
+	mu      sync.Mutex
+	objects map[string][]byte // 完整对象键 -> 内容
+	types   map[string]string
+	ranges  []string // 收到的 Range 请求头
+	lists   int      // 收到的列表请求数
+
+	rejectSignature bool // 签名错误属于预期，不报告测试失败
+
+	// stream 不为空时，读取该键会先返回一半内容，等待 release 关闭后再返回剩余内容
+	stream  string
+	release chan struct{}
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *S3Storage) {
+	t.Helper()
+	fake := &fakeS3{
+		t:       t,
+		objects: make(map[string][]byte),
+		types:   make(map[string]string),
+		release: make(chan struct{}),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	store, err := NewS3Storage(S3Config{
+		Endpoint:  server.URL,
+		Region:    testRegion,
+		Bucket:    testBucket,
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+		Prefix:    "uploads/",
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake, store
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := f.verify(r); err != nil {
+		if !f.rejectSignature {
+			f.t.Errorf("%s %s 签名无效: %v", r.Method, r.URL, err)
+		}
+		writeS3Error(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+	key, found := strings.CutPrefix(r.URL.Path, "/"+testBucket+"/")
+	if !found && r.URL.Path == "/"+testBucket && r.Method == http.MethodGet {
+		f.list(w, r)
+		return
+	}
+	if !found || key == "" {
+		writeS3Error(w, http.StatusBadRequest, "InvalidRequest")
+		return
+	}
+
+	f.mu.Lock()
+	data, exists := f.objects[key]
+	contentType := f.types[key]
+	f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		f.mu.Lock()
+		f.objects[key] = body
+		f.types[key] = r.Header.Get("Content-Type")
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	case http.MethodHead, http.MethodGet:
+		if !exists {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Last-Modified", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Format(http.TimeFormat))
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if value := r.Header.Get("Range"); value != "" {
+			f.mu.Lock()
+			f.ranges = append(f.ranges, value)
+			f.mu.Unlock()
+			var start, end int
+			if _, err := fmt.Sscanf(value, "bytes=%d-%d", &start, &end); err != nil || start > end || end >= len(data) {
+				writeS3Error(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+				return
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[start : end+1])
+			return
+		}
+		if key == f.stream {
+			half := len(data) / 2
+			w.WriteHeader(http.StatusOK)
+			w.Write(data[:half])
+			w.(http.Flusher).Flush()
+			<-f.release
+			w.Write(data[half:])
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	case http.MethodDelete:
+		f.mu.Lock()
+		delete(f.objects, key)
+		delete(f.types, key)
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// list 按键排序后每页返回 testPageSize 个对象，续传令牌为下一页第一个键
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("list-type") != "2" {
+		writeS3Error(w, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	prefix, token := query.Get("prefix"), query.Get("continuation-token")
+
+	f.mu.Lock()
+	f.lists++
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) && key >= token {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	type content struct {
+		Key          string
+		Size         int
+		LastModified string
+	}
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []content
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+	}{}
+	for i, key := range keys {
+		if i == testPageSize {
+			result.IsTruncated = true
+			result.NextContinuationToken = key
+			break
+		}
+		result.Contents = append(result.Contents, content{Key: key, Size: len(f.objects[key]), LastModified: "2024-01-02T03:04:05.000Z"})
+	}
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+// verify 按 SigV4 规则独立重建签名并与 Authorization 请求头比较
+func (f *fakeS3) verify(r *http.Request) error {
+	auth := r.Header.Get("Authorization")
+	rest, found := strings.CutPrefix(auth, "AWS4-HMAC-SHA256 ")
+	if !found {
+		return fmt.Errorf("缺少 AWS4-HMAC-SHA256 签名: %q", auth)
+	}
+	fields := map[string]string{}
+	for _, part := range strings.Split(rest, ", ") {
+		name, value, _ := strings.Cut(part, "=")
+		fields[name] = value
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	if len(amzDate) != len("20060102T150405Z") {
+		return fmt.Errorf("无效的 X-Amz-Date: %q", amzDate)
+	}
+	scope := amzDate[:8] + "/" + testRegion + "/s3/aws4_request"
+	if want := testAccessKey + "/" + scope; fields["Credential"] != want {
+		return fmt.Errorf("Credential = %q, want %q", fields["Credential"], want)
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash != unsignedPayload {
+		return fmt.Errorf("X-Amz-Content-Sha256 = %q", payloadHash)
+	}
+
+	signedHeaders := strings.Split(fields["SignedHeaders"], ";")
+	if !sort.StringsAreSorted(signedHeaders) {
+		return fmt.Errorf("SignedHeaders 未排序: %v", signedHeaders)
+	}
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		if value == "" {
+			return fmt.Errorf("签名的请求头 %s 不存在", name)
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	for _, required := range []string{"host", "x-amz-content-sha256", "x-amz-date"} {
+		if !strings.Contains(";"+fields["SignedHeaders"]+";", ";"+required+";") {
+			return fmt.Errorf("未签名 %s", required)
+		}
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		canonicalQuery(r.URL.Query()),
+		canonicalHeaders.String(),
+		fields["SignedHeaders"],
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+	key := hmacSHA256([]byte("AWS4"+testSecretKey), amzDate[:8])
+	for _, part := range []string{testRegion, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	if want := hex.EncodeToString(hmacSHA256(key, stringToSign)); fields["Signature"] != want {
+		return fmt.Errorf("Signature = %q, want %q", fields["Signature"], want)
+	}
+	return nil
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func TestS3StoragePutGetStat(t *testing.T) {
+	fake, store := newFakeS3(t)
+	ctx := context.Background()
+
+	// 键中的空格与中文需要按 SigV4 规则编码后签名
+	key := "照片 1.jpg"
+	if err := store.Put(ctx, key, strings.NewReader("hello world"), -1, "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got := string(fake.objects["uploads/"+key]); got != "hello world" {
+		t.Fatalf("存储内容 = %q", got)
+	}
+
+	data, err := ReadAll(ctx, store, key)
+	if err != nil || string(data) != "hello world" {
+		t.Fatalf("Get = %q, %v", data, err)
+	}
+	info, err := store.Stat(ctx, key)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Size != 11 || info.ContentType != "image/jpeg" || info.ModTime.IsZero() {
+		t.Errorf("Stat = %+v", info)
+	}
+}
+
+func TestS3StorageGetRange(t *testing.T) {
+	fake, store := newFakeS3(t)
+	ctx := context.Background()
+	if err := store.Put(ctx, "a.bin", strings.NewReader("0123456789"), 10, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, 4, "0123"},
+		{3, 5, "34567"},
+		{9, 1, "9"},
+		{5, 0, ""},
+	}
+	for _, tt := range tests {
+		r, err := store.GetRange(ctx, "a.bin", tt.offset, tt.length)
+		if err != nil {
+			t.Fatalf("GetRange(%d, %d): %v", tt.offset, tt.length, err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil || string(data) != tt.want {
+			t.Errorf("GetRange(%d, %d) = %q, %v, want %q", tt.offset, tt.length, data, err, tt.want)
+		}
+	}
+	if want := []string{"bytes=0-3", "bytes=3-7", "bytes=9-9"}; strings.Join(fake.ranges, ",") != strings.Join(want, ",") {
+		t.Errorf("Range 请求头 = %v, want %v", fake.ranges, want)
+	}
+
+	if _, err := store.GetRange(ctx, "a.bin", 20, 5); err == nil {
+		t.Error("超出对象大小的范围应返回错误")
+	}
+	if _, err := store.GetRange(ctx, "missing.bin", 0, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetRange 不存在的对象 = %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3StorageGetStreams(t *testing.T) {
+	fake, store := newFakeS3(t)
+	ctx := context.Background()
+	content := strings.Repeat("x", 64<<10) + strings.Repeat("y", 64<<10)
+	if err := store.Put(ctx, "big.bin", strings.NewReader(content), int64(len(content)), ""); err != nil {
+		t.Fatal(err)
+	}
+	fake.stream = "uploads/big.bin"
+
+	// 服务端在返回前一半内容后阻塞，Get 必须在响应体结束前返回，不能先读入内存
+	type result struct {
+		r   io.ReadCloser
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		r, err := store.Get(ctx, "big.bin")
+		done <- result{r, err}
+	}()
+	var got result
+	select {
+	case got = <-done:
+	case <-time.After(5 * time.Second):
+		close(fake.release)
+		t.Fatal("Get 等待完整响应体后才返回")
+	}
+	if got.err != nil {
+		close(fake.release)
+		t.Fatalf("Get: %v", got.err)
+	}
+	defer got.r.Close()
+
+	head := make([]byte, 64<<10)
+	if _, err := io.ReadFull(got.r, head); err != nil || strings.Trim(string(head), "x") != "" {
+		close(fake.release)
+		t.Fatalf("读取前一半内容失败: %v", err)
+	}
+	close(fake.release)
+	tail, err := io.ReadAll(got.r)
+	if err != nil || string(head)+string(tail) != content {
+		t.Fatalf("读取剩余内容失败: %d 字节, %v", len(tail), err)
+	}
+}
+
+func TestS3StorageListPagination(t *testing.T) {
+	fake, store := newFakeS3(t)
+	ctx := context.Background()
+	keys := []string{"a.jpg", "b.jpg", "c.png", "d.png", "e.webp"}
+	for _, key := range keys {
+		if err := store.Put(ctx, key, strings.NewReader(key), -1, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 其他前缀与更深层级的对象不应出现在列表中
+	fake.objects["compressed/x.jpg"] = []byte("x")
+	fake.objects["uploads/sub/y.jpg"] = []byte("y")
+
+	objects, err := store.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var got []string
+	for _, object := range objects {
+		got = append(got, object.Key)
+		if object.Size != int64(len(object.Key)) || object.ModTime.IsZero() {
+			t.Errorf("List 返回 %+v", object)
+		}
+	}
+	if strings.Join(got, ",") != strings.Join(keys, ",") {
+		t.Errorf("List = %v, want %v", got, keys)
+	}
+	if fake.lists != 3 {
+		t.Errorf("列表请求数 = %d, want 3", fake.lists)
+	}
+
+	objects, err = store.List(ctx, "c")
+	if err != nil || len(objects) != 1 || objects[0].Key != "c.png" {
+		t.Errorf("List(c) = %+v, %v", objects, err)
+	}
+}
+
+func TestS3StorageNotFound(t *testing.T) {
+	_, store := newFakeS3(t)
+	ctx := context.Background()
+
+	if _, err := store.Get(ctx, "missing.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Stat(ctx, "missing.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "missing.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete = %v, want ErrNotFound", err)
+	}
+
+	if err := store.Put(ctx, "a.jpg", strings.NewReader("a"), 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, "a.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "a.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("删除后 Get = %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3StorageInvalidKey(t *testing.T) {
+	_, store := newFakeS3(t)
+	ctx := context.Background()
+	for _, key := range []string{"../a.jpg", "a/b.jpg", ".env", ""} {
+		if err := store.Put(ctx, key, strings.NewReader("a"), 1, ""); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) = %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := store.Get(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Get(%q) = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
+
+func TestS3StorageWrongSecret(t *testing.T) {
+	fake, store := newFakeS3(t)
+	// 密钥错误时服务端拒绝请求
+	fake.rejectSignature = true
+	store.config.SecretKey = "wrong"
+	err := store.Put(context.Background(), "a.jpg", strings.NewReader("a"), 1, "")
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("Put = %v, want SignatureDoesNotMatch", err)
+	}
+}
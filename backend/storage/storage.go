@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("文件不存在")
+
+// ErrInvalidKey 对象键无效
+var ErrInvalidKey = errors.New("无效的文件名")
+
+// ObjectInfo 对象信息
+type ObjectInfo struct {
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"modTime"`
+	ContentType string    `json:"contentType,omitempty"`
+}
+
+// Storage 文件存储接口，键为不含目录的文件名
+type Storage interface {
+	// Put 写入对象，size 未知时传 -1
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get 读取对象，调用方负责关闭
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Stat 获取对象信息
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// List 列出键以 prefix 开头的对象
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// Delete 删除对象
+	Delete(ctx context.Context, key string) error
+	// URL 返回对象的访问地址
+	URL(key string) string
+}
+
+// RangeReader 支持按字节范围读取的存储，用于响应 Range 请求
+type RangeReader interface {
+	// GetRange 读取从 offset 开始的 length 个字节，调用方负责关闭
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+}
+
+// maxKeyLength 对象键的最大字节数
+const maxKeyLength = 255
+
+// ValidateKey 校验对象键，所有存储实现与文件接口统一使用
+// 只允许不含路径分隔符、控制字符与双向文本控制字符且不以点开头的 UTF-8 文件名
+func ValidateKey(key string) error {
+	if key == "" || len(key) > maxKeyLength || !utf8.ValidString(key) ||
+		strings.HasPrefix(key, ".") || strings.ContainsAny(key, `/\`) {
+		return ErrInvalidKey
+	}
+	for _, r := range key {
+		if unicode.IsControl(r) || unicode.Is(unicode.Bidi_Control, r) {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
+
+// joinURL 拼接访问地址前缀与对象键
+func joinURL(prefix, key string) string {
+	return strings.TrimSuffix(prefix, "/") + "/" + path.Base("/"+key)
+}
+
+// ReadAll 读取整个对象
+func ReadAll(ctx context.Context, s Storage, key string) ([]byte, error) {
+	r, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
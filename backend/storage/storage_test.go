@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		valid bool
+	}{
+		{"普通文件名", "0123456789abcdef0123456789abcdef.jpg", true},
+		{"中文文件名", "照片.png", true},
+		{"emoji 文件名", "😀.webp", true},
+		{"255 字节", strings.Repeat("a", 255), true},
+		{"空文件名", "", false},
+		{"上级目录", "..", false},
+		{"相对路径", "../etc/passwd", false},
+		{"子目录", "a/b.jpg", false},
+		{"绝对路径", "/etc/passwd", false},
+		{"Windows 路径", `C:\Windows\win.ini`, false},
+		{"反斜杠", `..\a.jpg`, false},
+		{"隐藏文件", ".env", false},
+		{"NUL 字符", "a\x00.jpg", false},
+		{"换行符", "a\n.jpg", false},
+		{"RTL 覆盖字符", "invoice\u202egpj.exe", false},
+		{"双向隔离字符", "\u2066a.jpg", false},
+		{"无效 UTF-8", "\xff.jpg", false},
+		{"256 字节", strings.Repeat("a", 256), false},
+		{"超过 255 字节的中文", strings.Repeat("图", 86), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateKey(tt.key)
+			if tt.valid && err != nil {
+				t.Errorf("ValidateKey(%q) = %v, want nil", tt.key, err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("ValidateKey(%q) = %v, want ErrInvalidKey", tt.key, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// GetEnvInt 读取整数环境变量，缺失或无效时返回默认值
+func GetEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// GetEnv 读取字符串环境变量，缺失或为空时返回默认值
+func GetEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// GetEnvBool 读取布尔环境变量，缺失或无效时返回默认值
+func GetEnvBool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// GetEnvDuration 读取时长环境变量（如 24h、10m），缺失或无效时返回默认值
+func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// GetEnvByteSize 读取文件大小环境变量（如 512MB、10G），缺失或无效时返回默认值
+func GetEnvByteSize(key string, defaultValue int64) int64 {
+	if value, err := ParseByteSize(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
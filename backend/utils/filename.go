@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxDisplayNameRunes = 100 // 显示文件名的最大字符数（含扩展名）
+	maxDisplayNameBytes = 255 // 显示文件名的最大字节数，多数文件系统不支持更长的文件名
+)
+
+// SanitizeFilename 将客户端提供的文件名清理为可安全展示的名称
+// 去掉目录部分、控制字符与双向文本控制字符，替换系统保留字符，并限制长度；结果只用于展示，不参与存储路径
+func SanitizeFilename(name string) string {
+	name = strings.ToValidUTF8(name, "")
+	// 同时按两种分隔符取最后一段
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r) || unicode.Is(unicode.Bidi_Control, r) || r == utf8.RuneError:
+			return -1
+		case strings.ContainsRune(`<>:"|?*`, r):
+			return '_'
+		case unicode.IsSpace(r):
+			return ' '
+		}
+		return r
+	}, name)
+	ext := strings.TrimSpace(filepath.Ext(name))
+	if ext == "." || utf8.RuneCountInString(ext) > 10 {
+		ext = ""
+	}
+	stem := []rune(strings.Trim(strings.TrimSuffix(name, ext), " ."))
+	if limit := maxDisplayNameRunes - utf8.RuneCountInString(ext); len(stem) > limit {
+		stem = stem[:limit]
+	}
+	for len(stem) > 0 && len(string(stem))+len(ext) > maxDisplayNameBytes {
+		stem = stem[:len(stem)-1]
+	}
+	name = strings.TrimRight(string(stem), " .")
+	if name == "" {
+		name = "image"
+	}
+	return name + ext
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"普通文件名", "photo.jpg", "photo.jpg"},
+		{"中文文件名", "照片 2024.jpg", "照片 2024.jpg"},
+		{"相对路径", "../../etc/passwd.png", "passwd.png"},
+		{"绝对路径", "/var/www/cat.png", "cat.png"},
+		{"Windows 路径", `C:\Users\a\cat.png`, "cat.png"},
+		{"NUL 字符", "a\x00b.jpg", "ab.jpg"},
+		{"换行与制表符", "a\nb\tc.jpg", "abc.jpg"},
+		{"RTL 覆盖字符", "invoice\u202egpj.exe", "invoicegpj.exe"},
+		{"双向隔离字符", "\u2067photo\u2069.jpg", "photo.jpg"},
+		{"保留字符", `a<b>:"c|d?*.jpg`, "a_b___c_d__.jpg"},
+		{"无效 UTF-8", "\xffphoto.jpg", "photo.jpg"},
+		{"只有点", "...", "image"},
+		{"空文件名", "", "image"},
+		{"以点开头", "..hidden.jpg", "hidden.jpg"},
+		{"过长扩展名", "a.verylongextension", "a.verylongextension"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeFilename(tt.input); got != tt.want {
+				t.Errorf("SanitizeFilename(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilenameLength(t *testing.T) {
+	inputs := []string{
+		strings.Repeat("a", 300) + ".jpg",
+		strings.Repeat("图", 300) + ".jpg",
+		strings.Repeat("😀", 300) + ".png",
+		strings.Repeat("é", 200) + ".webp",
+	}
+	for _, input := range inputs {
+		got := SanitizeFilename(input)
+		if len(got) > maxDisplayNameBytes {
+			t.Errorf("SanitizeFilename(%d 字节) 长度 %d 字节，超过 %d", len(input), len(got), maxDisplayNameBytes)
+		}
+		if n := utf8.RuneCountInString(got); n > maxDisplayNameRunes {
+			t.Errorf("SanitizeFilename(%d 字节) 长度 %d 个字符，超过 %d", len(input), n, maxDisplayNameRunes)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("SanitizeFilename(%d 字节) = %q，不是有效的 UTF-8", len(input), got)
+		}
+		if input[len(input)-4:] != got[len(got)-4:] {
+			t.Errorf("SanitizeFilename(%d 字节) = %q，扩展名被截断", len(input), got)
+		}
+	}
+}
+
+func TestSanitizeFilenameNoUnsafeRunes(t *testing.T) {
+	got := SanitizeFilename("a/\\b\x00\x1f\u202a\u202e\u200e\u2066c.png")
+	for _, r := range got {
+		if r == '/' || r == '\\' || unicode.IsControl(r) || unicode.Is(unicode.Bidi_Control, r) {
+			t.Errorf("SanitizeFilename 结果 %q 包含不安全字符 %U", got, r)
+		}
+	}
+}
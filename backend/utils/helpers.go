@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"errors"
 	"strconv"
+	"strings"
 )
 
 // ParseID 解析字符串ID为整数
@@ -13,6 +15,38 @@ func ParseID(idStr string) (int, error) {
 	return id, nil
 }
 
+// byteUnits 文件大小单位
+var byteUnits = []struct {
+	suffix string
+	factor int64
+}{
+	{"KB", 1024},
+	{"MB", 1024 * 1024},
+	{"GB", 1024 * 1024 * 1024},
+	{"K", 1024},
+	{"M", 1024 * 1024},
+	{"G", 1024 * 1024 * 1024},
+	{"B", 1},
+}
+
+// ParseByteSize 解析文件大小，支持纯数字（字节）以及 B、K/KB、M/MB、G/GB 后缀
+func ParseByteSize(input string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(input))
+	factor := int64(1)
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			factor = unit.factor
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value < 0 {
+		return 0, errors.New("无效的文件大小: " + input)
+	}
+	return int64(value * float64(factor)), nil
+}
+
 // ResponseError 错误响应结构
 type ResponseError struct {
 	Error string `json:"error"`
@@ -35,4 +69,5 @@ type LegacySuccessResponse struct {
 type LegacyErrorResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
+	Code    string `json:"code,omitempty"`
 }
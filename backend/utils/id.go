@@ -0,0 +1,15 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// NewID 生成 32 位十六进制随机 ID
+func NewID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("生成随机 ID 失败: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}